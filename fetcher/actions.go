@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// writeGitHubOutput appends a few headline numbers as name=value lines to
+// the file GitHub Actions names in GITHUB_OUTPUT, so later workflow steps
+// can use them as step outputs. Outside of Actions it does nothing.
+func writeGitHubOutput(sum summary) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "total_stars=%d\n", sum.Engagement.TotalStars)
+	fmt.Fprintf(&b, "repo_count=%d\n", sum.RepoCounts.Total)
+	fmt.Fprintf(&b, "total_size_human=%s\n", sum.Size.Human)
+	fmt.Fprintf(&b, "stats_pending=%d\n", sum.Enrichment.ReposStatsPending)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// loadAnnotations reads a sidecar file mapping repo full names to arbitrary
+// string key-values, e.g. {"me/tool": {"team": "infra", "status": "deprecated"}}.
+func loadAnnotations(path string) (map[string]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ann map[string]map[string]string
+	if err := json.Unmarshal(data, &ann); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ann, nil
+}
+
+// applyAnnotations attaches annotations to matching repos and warns about
+// entries that matched nothing, which are usually typos or renamed repos.
+func applyAnnotations(out []outRepo, ann map[string]map[string]string) {
+	matched := map[string]bool{}
+	for i := range out {
+		if a, ok := ann[out[i].FullName]; ok {
+			out[i].Annotations = a
+			matched[out[i].FullName] = true
+		}
+	}
+
+	var unmatched []string
+	for name := range ann {
+		if !matched[name] {
+			unmatched = append(unmatched, name)
+		}
+	}
+	sort.Strings(unmatched)
+	for _, name := range unmatched {
+		logWarnf("⚠️  Annotation for %s matches no repository", name)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// backoffPolicy is an exponential backoff with jitter: retry n waits
+// base×factor^(n-1), capped at max, of which a random part is taken so
+// workers that failed together don't retry in lockstep. attempts is how
+// many retries the caller makes before giving up.
+type backoffPolicy struct {
+	base     time.Duration
+	factor   float64
+	max      time.Duration
+	attempts int
+}
+
+// retryBackoff paces every retry of the run: transient network errors,
+// secondary rate limits without a Retry-After (from ten times the base),
+// repo-level retries and, through statsBackoff, 202s from the stats
+// endpoints. Set from --retry-base, --retry-factor and --retry-max; how
+// many retries each gets has its own flag.
+var retryBackoff = backoffPolicy{base: 500 * time.Millisecond, factor: 2, max: 30 * time.Second}
+
+// statsBackoff paces the polls of a stats endpoint still computing: the
+// waits of retryBackoff, --stats-polls of them.
+var statsBackoff = backoffPolicy{base: 500 * time.Millisecond, factor: 2, max: 30 * time.Second, attempts: 4}
+
+// delay is the un-jittered wait before retry n (from 1).
+func (p backoffPolicy) delay(n int) time.Duration {
+	d := float64(p.base) * math.Pow(p.factor, float64(max(n-1, 0)))
+	if d > float64(p.max) {
+		return p.max
+	}
+	return time.Duration(d)
+}
+
+// wait is the wait before retry n: "equal jitter", a uniformly random
+// duration between half of delay(n) and all of it, so the waits still grow
+// but never shrink to nothing.
+func (p backoffPolicy) wait(n int) time.Duration {
+	d := p.delay(n)
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBackoffWaitBounds(t *testing.T) {
+	policies := []backoffPolicy{
+		{base: 500 * time.Millisecond, factor: 2, max: 30 * time.Second},
+		{base: time.Second, factor: 1.5, max: 10 * time.Second},
+		{base: 100 * time.Millisecond, factor: 1, max: time.Second},
+		{base: time.Millisecond, factor: 3, max: time.Millisecond},
+	}
+	for _, p := range policies {
+		for n := 1; n <= 12; n++ {
+			// Retry n waits base×factor^(n-1), capped, of which at least half
+			ceiling := time.Duration(math.Min(float64(p.base)*math.Pow(p.factor, float64(n-1)), float64(p.max)))
+			if got := p.delay(n); got != ceiling {
+				t.Errorf("%+v: delay(%d) = %s, want %s", p, n, got, ceiling)
+			}
+			for i := 0; i < 200; i++ {
+				if d := p.wait(n); d < ceiling/2 || d > ceiling {
+					t.Fatalf("%+v: wait(%d) = %s, want within [%s, %s]", p, n, d, ceiling/2, ceiling)
+				}
+			}
+		}
+	}
+}
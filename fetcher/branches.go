@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// fetchBranchTips lists up to max branches of a repo with their tip SHAs.
+func fetchBranchTips(ctx context.Context, client *http.Client, token, fullName string, max int) (map[string]string, error) {
+	tips := map[string]string{}
+	for page := 1; len(tips) < max; page++ {
+		url := apiURL(fmt.Sprintf("/repos/%s/branches?per_page=100&page=%d", fullName, page))
+		status, body, err := doGET(ctx, client, url, token)
+		if err != nil {
+			return nil, err
+		}
+		if status < 200 || status >= 300 {
+			return nil, fmt.Errorf("branches error %d", status)
+		}
+
+		var branches []struct {
+			Name   string `json:"name"`
+			Commit struct {
+				SHA string `json:"sha"`
+			} `json:"commit"`
+		}
+		if err := json.Unmarshal(body, &branches); err != nil {
+			return nil, err
+		}
+		for _, b := range branches {
+			if len(tips) == max {
+				break
+			}
+			tips[b.Name] = b.Commit.SHA
+		}
+		if len(branches) < 100 {
+			break
+		}
+	}
+	return tips, nil
+}
+
+// fetchBranchCount counts a repo's branches with a single request.
+func fetchBranchCount(ctx context.Context, client *http.Client, token, fullName string) (int, error) {
+	return countViaLink(ctx, client, token, apiURL(fmt.Sprintf("/repos/%s/branches?per_page=1", fullName)))
+}
+
+// fetchBranchProtected reports whether a branch has protection rules; a
+// 404 means it has none. known is false on a 403: reading the rules needs
+// admin access (and, for private repos, a plan with branch protection).
+func fetchBranchProtected(ctx context.Context, client *http.Client, token, fullName, branch string) (protected, known bool, err error) {
+	u := apiURL(fmt.Sprintf("/repos/%s/branches/%s/protection", fullName, url.PathEscape(branch)))
+	status, _, err := doGET(ctx, client, u, token)
+	if err != nil {
+		return false, false, err
+	}
+	switch {
+	case status == 403:
+		protectionDeniedOnce.Do(func() {
+			logWarnf("⚠️  Branch protection needs admin access; default_branch_protected is left out where the token lacks it")
+		})
+		logDebugf("  %s: branch protection not readable (403)", fullName)
+		return false, false, nil
+	case status == 404:
+		return false, true, nil
+	case status < 200 || status >= 300:
+		return false, false, fmt.Errorf("branch protection error %d", status)
+	}
+	return true, true, nil
+}
+
+// protectionDeniedOnce keeps the missing admin access warning to one line
+// per run.
+var protectionDeniedOnce sync.Once
+
+// fetchCommitDate returns the author date of one commit.
+func fetchCommitDate(ctx context.Context, client *http.Client, token, fullName, sha string) (time.Time, error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/commits/%s", fullName, sha))
+	status, body, err := doGET(ctx, client, url, token)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if status < 200 || status >= 300 {
+		return time.Time{}, fmt.Errorf("commit error %d", status)
+	}
+
+	var c commitListItem
+	if err := json.Unmarshal(body, &c); err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, c.Commit.Author.Date)
+}
+
+// fetchLatestCommitAnyBranch returns the newest tip commit date among the
+// first max branches. known is the default branch head (SHA and date)
+// when already fetched, which saves a call for the branches pointing at
+// it. Several branches at the same commit are checked once.
+func fetchLatestCommitAnyBranch(ctx context.Context, client *http.Client, token, fullName string, max int, knownSHA string, knownDate time.Time) (time.Time, error) {
+	tips, err := fetchBranchTips(ctx, client, token, fullName, max)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	latest := knownDate
+	checked := map[string]bool{knownSHA: knownSHA != ""}
+	for _, sha := range tips {
+		if checked[sha] {
+			continue
+		}
+		checked[sha] = true
+		date, err := fetchCommitDate(ctx, client, token, fullName, sha)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if date.After(latest) {
+			latest = date
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseBucketBounds parses a comma-separated, strictly increasing list of
+// positive bucket lower bounds such as "1,10,100,1000".
+func parseBucketBounds(spec string) ([]int, error) {
+	var bounds []int
+	for _, part := range strings.Split(spec, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("bucket bound %q is not a positive integer", part)
+		}
+		if len(bounds) > 0 && n <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("bucket bounds must be increasing: %s", spec)
+		}
+		bounds = append(bounds, n)
+	}
+	return bounds, nil
+}
+
+// bucketLabels names the len(bounds)+1 buckets the bounds split the
+// non-negative integers into, e.g. 1,10,100 gives 0, 1-9, 10-99, 100+.
+func bucketLabels(bounds []int) []string {
+	labels := make([]string, 0, len(bounds)+1)
+	lo := 0
+	for _, b := range bounds {
+		if b-1 == lo {
+			labels = append(labels, strconv.Itoa(lo))
+		} else {
+			labels = append(labels, fmt.Sprintf("%d-%d", lo, b-1))
+		}
+		lo = b
+	}
+	return append(labels, fmt.Sprintf("%d+", lo))
+}
+
+// bucketIndex returns which bucket v falls into.
+func bucketIndex(v int, bounds []int) int {
+	i := 0
+	for i < len(bounds) && v >= bounds[i] {
+		i++
+	}
+	return i
+}
+
+// distribution is a bucketed count in array form, ordered like its labels,
+// ready for a sparkline.
+type distribution struct {
+	Labels []string `json:"labels"`
+	Counts []int    `json:"counts"`
+}
+
+func newDistribution(bounds []int) distribution {
+	return distribution{Labels: bucketLabels(bounds), Counts: make([]int, len(bounds)+1)}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// defaultCacheDir is where --cache keeps responses when no dir is given.
+const defaultCacheDir = ".gitlore-cache"
+
+// cacheDir enables the ETag cache when set (--cache).
+var cacheDir string
+
+// cachedResponse is one 200 response kept for conditional requests. Only the
+// Link header is kept, since pagination is all callers read from headers.
+type cachedResponse struct {
+	URL  string          `json:"url"`
+	ETag string          `json:"etag"`
+	Link string          `json:"link,omitempty"`
+	Body json.RawMessage `json:"body"`
+}
+
+// cacheFile names a URL's entry. Entries are per token, so one token never
+// gets another's private data replayed.
+func cacheFile(url, token string) string {
+	sum := sha256.Sum256([]byte(token + " " + url))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// loadCached returns the cached response for url, if any.
+func loadCached(url, token string) (cachedResponse, bool) {
+	if cacheDir == "" {
+		return cachedResponse{}, false
+	}
+	data, err := os.ReadFile(cacheFile(url, token))
+	if err != nil {
+		return cachedResponse{}, false
+	}
+	var c cachedResponse
+	if err := json.Unmarshal(data, &c); err != nil || c.URL != url || c.ETag == "" {
+		return cachedResponse{}, false
+	}
+	return c, true
+}
+
+// storeCached keeps a 200 response that came with an ETag. Failures only
+// cost a cache miss next time, so they're ignored.
+func storeCached(url, token string, h http.Header, body []byte) {
+	etag := h.Get("ETag")
+	if cacheDir == "" || etag == "" || !json.Valid(body) {
+		return
+	}
+	data, err := json.Marshal(cachedResponse{URL: url, ETag: etag, Link: h.Get("Link"), Body: body})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return
+	}
+	_ = writeFileAtomic(cacheFile(url, token), data)
+}
+
+// header rebuilds the headers callers may read from a cached response.
+func (c cachedResponse) header() http.Header {
+	h := http.Header{}
+	if c.Link != "" {
+		h.Set("Link", c.Link)
+	}
+	return h
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// repoChanges is what changed between a previous index and this run
+// (--compare), matched by full name.
+type repoChanges struct {
+	Previous    string `json:"previous"`
+	GeneratedAt string `json:"generated_at"`
+
+	Added         []string     `json:"added"`
+	Removed       []string     `json:"removed"`
+	NewlyArchived []string     `json:"newly_archived"`
+	Changed       []repoChange `json:"changed"`
+
+	TotalStarsDelta int `json:"total_stars_delta"`
+}
+
+// repoChange lists the tracked fields that differ for one repo; the others
+// are left out.
+type repoChange struct {
+	FullName      string        `json:"full_name"`
+	Stars         *intChange    `json:"stars,omitempty"`
+	Forks         *intChange    `json:"forks,omitempty"`
+	OpenIssues    *intChange    `json:"open_issues,omitempty"`
+	LastCommitAt  *stringChange `json:"last_commit_at,omitempty"`
+	LatestRelease *stringChange `json:"latest_release_tag,omitempty"`
+}
+
+type intChange struct {
+	Before int `json:"before"`
+	After  int `json:"after"`
+	Delta  int `json:"delta"`
+}
+
+type stringChange struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// compareIndexes diffs the previous index (read from prevPath) against
+// out. Everything is sorted by full name, so the file diffs well too.
+func compareIndexes(prevPath string, prev, out []outRepo) repoChanges {
+	ch := repoChanges{
+		Previous:      prevPath,
+		GeneratedAt:   formatTimestamp(time.Now()),
+		Added:         []string{},
+		Removed:       []string{},
+		NewlyArchived: []string{},
+		Changed:       []repoChange{},
+	}
+
+	before := make(map[string]outRepo, len(prev))
+	for _, p := range prev {
+		before[p.FullName] = p
+	}
+	current := make(map[string]bool, len(out))
+	for _, r := range out {
+		current[r.FullName] = true
+		p, ok := before[r.FullName]
+		if !ok {
+			ch.Added = append(ch.Added, r.FullName)
+			ch.TotalStarsDelta += r.Stars
+			continue
+		}
+		if r.Archived && !p.Archived {
+			ch.NewlyArchived = append(ch.NewlyArchived, r.FullName)
+		}
+		ch.TotalStarsDelta += r.Stars - p.Stars
+
+		c := repoChange{
+			FullName:      r.FullName,
+			Stars:         diffInt(p.Stars, r.Stars),
+			Forks:         diffInt(p.Forks, r.Forks),
+			OpenIssues:    diffInt(p.OpenIssues, r.OpenIssues),
+			LastCommitAt:  diffTimestamp(p.LastCommitAt, r.LastCommitAt),
+			LatestRelease: diffString(p.LatestReleaseTag, r.LatestReleaseTag),
+		}
+		if c != (repoChange{FullName: r.FullName}) {
+			ch.Changed = append(ch.Changed, c)
+		}
+	}
+	for _, p := range prev {
+		if !current[p.FullName] {
+			ch.Removed = append(ch.Removed, p.FullName)
+			ch.TotalStarsDelta -= p.Stars
+		}
+	}
+
+	sort.Strings(ch.Added)
+	sort.Strings(ch.Removed)
+	sort.Strings(ch.NewlyArchived)
+	sort.Slice(ch.Changed, func(i, j int) bool { return ch.Changed[i].FullName < ch.Changed[j].FullName })
+	return ch
+}
+
+func diffInt(before, after int) *intChange {
+	if before == after {
+		return nil
+	}
+	return &intChange{Before: before, After: after, Delta: after - before}
+}
+
+func diffString(before, after string) *stringChange {
+	if before == after {
+		return nil
+	}
+	return &stringChange{Before: before, After: after}
+}
+
+// diffTimestamp is diffString for timestamps, which may have been written
+// in another --timezone: the same instant isn't a change.
+func diffTimestamp(before, after string) *stringChange {
+	b, errB := time.Parse(time.RFC3339, before)
+	a, errA := time.Parse(time.RFC3339, after)
+	if errB == nil && errA == nil && a.Equal(b) {
+		return nil
+	}
+	return diffString(before, after)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// ciMarkers maps root-level files and directories to the CI provider whose
+// config they are. GitHub Actions is checked separately, since .github
+// alone doesn't mean workflows.
+var ciMarkers = map[string]string{
+	".circleci":           "circleci",
+	".travis.yml":         "travis",
+	".gitlab-ci.yml":      "gitlab_ci",
+	"Jenkinsfile":         "jenkins",
+	"azure-pipelines.yml": "azure_pipelines",
+	".drone.yml":          "drone",
+}
+
+type contentEntry struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// fetchContentsDir lists a directory of the default branch. found is false
+// when it doesn't exist, or the repo is empty.
+func fetchContentsDir(ctx context.Context, client *http.Client, token, fullName, path string) ([]contentEntry, bool, error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/contents/%s", fullName, path))
+	status, body, err := doGET(ctx, client, url, token)
+	if err != nil {
+		return nil, false, err
+	}
+	if status == 404 {
+		return nil, false, nil
+	}
+	if status < 200 || status >= 300 {
+		return nil, false, fmt.Errorf("contents error %d", status)
+	}
+
+	var entries []contentEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		// A file rather than a directory
+		return nil, false, nil
+	}
+	return entries, true, nil
+}
+
+// fetchCIProviders detects the CI providers configured in a repo from its
+// root listing, plus a listing of .github/workflows when .github exists:
+// one or two calls per repo.
+func fetchCIProviders(ctx context.Context, client *http.Client, token, fullName string) ([]string, error) {
+	root, found, err := fetchContentsDir(ctx, client, token, fullName, "")
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var providers []string
+	hasDotGitHub := false
+	for _, e := range root {
+		if p, ok := ciMarkers[e.Name]; ok {
+			providers = append(providers, p)
+		}
+		if e.Name == ".github" && e.Type == "dir" {
+			hasDotGitHub = true
+		}
+	}
+	if hasDotGitHub {
+		workflows, found, err := fetchContentsDir(ctx, client, token, fullName, ".github/workflows")
+		if err != nil {
+			return nil, err
+		}
+		if found && len(workflows) > 0 {
+			providers = append(providers, "github_actions")
+		}
+	}
+	sort.Strings(providers)
+	return providers, nil
+}
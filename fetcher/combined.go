@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// combinedSchemaVersion is bumped when the combined report's layout changes.
+const combinedSchemaVersion = "1"
+
+// combinedReport is the single-file output of --combined: the summary and
+// the index side by side. Repos is kept raw so a --fields projection
+// carries over unchanged.
+type combinedReport struct {
+	SchemaVersion string          `json:"schema_version"`
+	GeneratedAt   string          `json:"generated_at"`
+	Summary       summary         `json:"summary"`
+	Repos         json.RawMessage `json:"repos"`
+}
+
+// writeCombined writes the summary and the already-encoded index to path
+// as one object.
+func writeCombined(path string, sum summary, indexJSON []byte) error {
+	report := combinedReport{
+		SchemaVersion: combinedSchemaVersion,
+		GeneratedAt:   sum.GeneratedAt,
+		Summary:       sum,
+		Repos:         indexJSON,
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a --config file, YAML or JSON, for scheduled runs. Each key is
+// the flag of the same name, so a flag's default and documentation apply;
+// keys left out keep the default. Flags given on the command line win
+// over the file, and the file wins over environment variables such as
+// GITHUB_API_URL, since those only provide flag defaults. For example:
+//
+//	token-file: /run/secrets/github_token
+//	workers: 4
+//	request-delay: 250ms
+//	include: [myorg/service-*]
+//	exclude: ["*-archive"]
+//	format: csv
+//	enrich: [releases, open-prs]
+type Config struct {
+	TokenFile    *string `yaml:"token-file" json:"token-file"`
+	BaseURL      *string `yaml:"base-url" json:"base-url"`
+	User         *string `yaml:"user" json:"user"`
+	Org          *string `yaml:"org" json:"org"`
+	API          *string `yaml:"api" json:"api"`
+	Workers      *int    `yaml:"workers" json:"workers"`
+	RequestDelay *string `yaml:"request-delay" json:"request-delay"`
+	Timeout      *string `yaml:"timeout" json:"timeout"`
+	Cache        *string `yaml:"cache" json:"cache"`
+	LogLevel     *string `yaml:"log-level" json:"log-level"`
+	LogFormat    *string `yaml:"log-format" json:"log-format"`
+
+	// Filters
+	Include         []string `yaml:"include" json:"include"`
+	Exclude         []string `yaml:"exclude" json:"exclude"`
+	Since           *string  `yaml:"since" json:"since"`
+	MinSize         *string  `yaml:"min-size" json:"min-size"`
+	MaxSize         *string  `yaml:"max-size" json:"max-size"`
+	IncludeForks    *bool    `yaml:"include-forks" json:"include-forks"`
+	IncludeArchived *bool    `yaml:"include-archived" json:"include-archived"`
+	IncludePrivate  *bool    `yaml:"include-private" json:"include-private"`
+	Where           *string  `yaml:"where" json:"where"`
+
+	// Output
+	OutputDir *string `yaml:"output-dir" json:"output-dir"`
+	Format    *string `yaml:"format" json:"format"`
+	Fields    *string `yaml:"fields" json:"fields"`
+	SQLite    *string `yaml:"sqlite" json:"sqlite"`
+
+	// Default enrichments, which false turns off
+	EnrichCommits      *bool `yaml:"enrich-commits" json:"enrich-commits"`
+	EnrichStats        *bool `yaml:"enrich-stats" json:"enrich-stats"`
+	EnrichLanguages    *bool `yaml:"enrich-languages" json:"enrich-languages"`
+	EnrichContributors *bool `yaml:"enrich-contributors" json:"enrich-contributors"`
+	EnrichCommunity    *bool `yaml:"enrich-community" json:"enrich-community"`
+
+	// Opt-in enrichments to turn on, by flag name
+	Enrich []string `yaml:"enrich" json:"enrich"`
+}
+
+// configEnrichments are the flags a config file's enrich list may name.
+var configEnrichments = []string{
+	"all-branches", "branches", "ci-detect", "code-frequency", "fork-compare", "issue-ratio", "lifetime-commits",
+	"merge-settings", "open-prs", "participation", "pr-authors", "releases", "workflow-permissions", "workflow-runs",
+}
+
+// loadConfig reads a config file; unknown keys are an error, so a typo
+// doesn't go unnoticed. JSON is read as the YAML subset it is.
+func loadConfig(path string) (Config, error) {
+	var cfg Config
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// apply sets the flags of fs that cfg has a value for, except those given
+// on the command line.
+func (cfg Config) apply(fs *flag.FlagSet) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	set := func(name, value string) error {
+		if given[name] {
+			return nil
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+
+	v := reflect.ValueOf(cfg)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("yaml")
+		field := v.Field(i)
+		switch {
+		case name == "enrich":
+			for _, e := range cfg.Enrich {
+				if !slices.Contains(configEnrichments, e) {
+					return fmt.Errorf("enrich: unknown enrichment %q", e)
+				}
+				if err := set(e, "true"); err != nil {
+					return err
+				}
+			}
+		case field.Kind() == reflect.Slice:
+			for j := 0; j < field.Len(); j++ {
+				if err := set(name, field.Index(j).String()); err != nil {
+					return err
+				}
+			}
+		case !field.IsNil():
+			var value string
+			switch e := field.Elem(); e.Kind() {
+			case reflect.Bool:
+				value = strconv.FormatBool(e.Bool())
+			case reflect.Int:
+				value = strconv.Itoa(int(e.Int()))
+			default:
+				value = e.String()
+			}
+			if err := set(name, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// indexCSV renders the index as CSV, one row per repo under a header of
+// its JSON keys (or just fields, when --fields is given). Nested values are
+// flattened so the table stays rectangular: lists of scalars are joined
+// with ";", maps become "key=value" pairs joined the same way, and lists of
+// objects are kept as compact JSON. A top_language column follows
+// language_breakdown.
+func indexCSV(out []outRepo, fields []string) ([]byte, error) {
+	if fields == nil {
+		fields = jsonFieldNames(reflect.TypeOf(outRepo{}))
+	}
+	header := make([]string, 0, len(fields)+1)
+	for _, f := range fields {
+		header = append(header, f)
+		if f == "language_breakdown" {
+			header = append(header, "top_language")
+		}
+	}
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, r := range out {
+		m, err := toJSONMap(r)
+		if err != nil {
+			return nil, err
+		}
+		row := make([]string, 0, len(header))
+		for _, f := range fields {
+			row = append(row, csvCell(m[f]))
+			if f == "language_breakdown" {
+				row = append(row, topLanguage(r.LanguageBreakdown))
+			}
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// csvCell flattens one generic JSON value into a cell.
+func csvCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				data, _ := json.Marshal(v)
+				return string(data)
+			}
+			parts[i] = csvCell(item)
+		}
+		return strings.Join(parts, ";")
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = k + "=" + csvCell(v[k])
+		}
+		return strings.Join(parts, ";")
+	}
+	return fmt.Sprint(v)
+}
+
+// topLanguage is the language with the most bytes, ties broken by name.
+func topLanguage(langs map[string]int) string {
+	top, most := "", -1
+	for lang, n := range langs {
+		if n > most || (n == most && lang < top) {
+			top, most = lang, n
+		}
+	}
+	return top
+}
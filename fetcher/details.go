@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ghRepoDetails is the part of the single-repo object (/repos/{full}) that
+// the listing endpoint doesn't return.
+type ghRepoDetails struct {
+	Parent *struct {
+		FullName      string `json:"full_name"`
+		DefaultBranch string `json:"default_branch"`
+		Owner         struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"parent"`
+
+	// Merge settings; only present when the token can push to the repo
+	AllowSquashMerge    *bool `json:"allow_squash_merge"`
+	AllowMergeCommit    *bool `json:"allow_merge_commit"`
+	AllowRebaseMerge    *bool `json:"allow_rebase_merge"`
+	DeleteBranchOnMerge *bool `json:"delete_branch_on_merge"`
+}
+
+func fetchRepoDetails(ctx context.Context, client *http.Client, token, fullName string) (ghRepoDetails, error) {
+	u := apiURL(fmt.Sprintf("/repos/%s", fullName))
+	status, body, err := doGET(ctx, client, u, token)
+	if err != nil {
+		return ghRepoDetails{}, err
+	}
+	if status < 200 || status >= 300 {
+		return ghRepoDetails{}, fmt.Errorf("repo details error %d", status)
+	}
+
+	var d ghRepoDetails
+	if err := json.Unmarshal(body, &d); err != nil {
+		return ghRepoDetails{}, err
+	}
+	return d, nil
+}
+
+// mergePolicy is the merge configuration repos are expected to have.
+type mergePolicy struct {
+	Squash       bool
+	Merge        bool
+	Rebase       bool
+	DeleteBranch bool
+}
+
+// parseMergePolicy parses a --merge-policy value: the merge methods that
+// should be allowed (squash, merge, rebase), optionally with delete-branch
+// when head branches should be deleted after merging.
+func parseMergePolicy(spec string) (mergePolicy, error) {
+	var p mergePolicy
+	for _, part := range strings.Split(spec, ",") {
+		switch strings.TrimSpace(part) {
+		case "squash":
+			p.Squash = true
+		case "merge":
+			p.Merge = true
+		case "rebase":
+			p.Rebase = true
+		case "delete-branch":
+			p.DeleteBranch = true
+		default:
+			return p, fmt.Errorf("unknown merge policy entry %q (want squash, merge, rebase, delete-branch)", part)
+		}
+	}
+	return p, nil
+}
+
+// deviations lists the settings of r that differ from the policy.
+func (p mergePolicy) deviations(r outRepo) []string {
+	var d []string
+	if r.AllowSquashMerge != p.Squash {
+		d = append(d, "squash")
+	}
+	if r.AllowMergeCommit != p.Merge {
+		d = append(d, "merge")
+	}
+	if r.AllowRebaseMerge != p.Rebase {
+		d = append(d, "rebase")
+	}
+	if r.DeleteBranchOnMerge != p.DeleteBranch {
+		d = append(d, "delete-branch")
+	}
+	sort.Strings(d)
+	return d
+}
@@ -0,0 +1,29 @@
+package main
+
+import "fmt"
+
+// printDryRun lists the repos a run would enrich and estimates the
+// requests that implies: one per enabled step per repo. Steps that page
+// (contributors, branches) or search twice (issues) cost more, and stats
+// still being computed are polled again, so treat it as a lower bound.
+func printDryRun(repos []ghRepo, opts options) {
+	perStep := make([]int, len(enrichSteps))
+	total := 0
+	for _, g := range repos {
+		fmt.Println(g.FullName)
+		r := outRepo{FullName: g.FullName, Fork: g.Fork, HasIssues: g.HasIssues}
+		for i, step := range enrichSteps {
+			if step.enabled(opts, &r) {
+				perStep[i]++
+				total++
+			}
+		}
+	}
+
+	fmt.Printf("\n%d repositories selected, about %d enrichment requests:\n", len(repos), total)
+	for i, step := range enrichSteps {
+		if perStep[i] > 0 {
+			fmt.Printf("  %-22s %d\n", step.name, perStep[i])
+		}
+	}
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
+
+// errStopEnrichment is returned by a step that has established the repo
+// can't be enriched further (deleted, still importing). It isn't recorded
+// as a failure.
+var errStopEnrichment = errors.New("stop enrichment")
+
+// enrichEnv is what enrichment steps share while enriching a single repo.
+type enrichEnv struct {
+	ctx    context.Context
+	client *http.Client
+	token  string
+	opts   options
+
+	details    *ghRepoDetails
+	detailsErr error
+}
+
+// repoDetails fetches /repos/{full} at most once per repo, for the steps
+// that need fields the listing doesn't carry.
+func (env *enrichEnv) repoDetails(fullName string) (ghRepoDetails, error) {
+	if env.details == nil && env.detailsErr == nil {
+		d, err := fetchRepoDetails(env.ctx, env.client, env.token, fullName)
+		env.details, env.detailsErr = &d, err
+	}
+	return *env.details, env.detailsErr
+}
+
+// enrichStep is one per-repo enrichment call. Its name identifies the
+// endpoint in the errors file, so failed steps can be replayed.
+type enrichStep struct {
+	name    string
+	enabled func(opts options, r *outRepo) bool
+	run     func(env *enrichEnv, r *outRepo) error
+}
+
+var enrichSteps = []enrichStep{
+	{"commits", func(o options, r *outRepo) bool { return o.EnrichCommits }, enrichLastCommit},
+	{"all_branches", func(o options, r *outRepo) bool { return o.AllBranches }, enrichLatestAnyBranch},
+	{"commit_activity", func(o options, r *outRepo) bool { return o.EnrichStats }, enrichCommitActivity},
+	{"code_frequency", func(o options, r *outRepo) bool { return o.CodeFrequency }, enrichCodeFrequency},
+	{"participation", func(o options, r *outRepo) bool { return o.Participation }, enrichParticipation},
+	{"languages", func(o options, r *outRepo) bool { return o.EnrichLanguages }, enrichLanguages},
+	{"contributors", func(o options, r *outRepo) bool { return o.EnrichContributors }, enrichContributors},
+	{"contributor_stats", func(o options, r *outRepo) bool { return o.LifetimeCommits }, enrichLifetimeCommits},
+	{"community", func(o options, r *outRepo) bool { return o.EnrichCommunity }, enrichCommunity},
+	{"open_prs", func(o options, r *outRepo) bool { return o.OpenPRs }, enrichOpenPRCount},
+	{"releases", func(o options, r *outRepo) bool { return o.Releases }, enrichLatestRelease},
+	{"issues", func(o options, r *outRepo) bool { return o.IssueRatio && r.HasIssues }, enrichIssueRatio},
+	{"merge_settings", func(o options, r *outRepo) bool { return o.MergeSettings }, enrichMergeSettings},
+	{"branches", func(o options, r *outRepo) bool { return o.Branches }, enrichBranches},
+	{"fork_compare", func(o options, r *outRepo) bool { return o.ForkCompare && r.Fork }, enrichForkCompare},
+	{"pulls", func(o options, r *outRepo) bool { return o.PRAuthors }, enrichPRAuthors},
+	{"ci", func(o options, r *outRepo) bool { return o.CIDetect }, enrichCIProviders},
+	{"workflow_permissions", func(o options, r *outRepo) bool { return o.WorkflowPermissions }, enrichWorkflowPermissions},
+	{"workflow_runs", func(o options, r *outRepo) bool { return o.WorkflowRuns }, enrichWorkflowRuns},
+}
+
+// enrichError is one failed enrichment step, as written to the errors file.
+type enrichError struct {
+	FullName string `json:"full_name"`
+	Endpoint string `json:"endpoint"`
+	Error    string `json:"error"`
+
+	// transient is whether a retry may succeed; see isTransient
+	transient bool
+}
+
+// enrichRepo fills in r's enrichment fields with the per-repo API calls and
+// returns the steps that failed. Failed steps leave their fields empty and
+// are recorded in r.EnrichmentErrors, so an empty field can be told from
+// one that couldn't be fetched.
+// When only is non-nil, just the steps it names are run, whatever the flags
+// say now: they were on in the run that listed them.
+func enrichRepo(ctx context.Context, client *http.Client, token string, opts options, r *outRepo, only map[string]bool) []enrichError {
+	env := &enrichEnv{ctx: ctx, client: client, token: token, opts: opts}
+
+	if only == nil {
+		r.EnrichedSteps = nil
+	}
+	var failed []enrichError
+	for _, step := range enrichSteps {
+		if only != nil && !only[step.name] {
+			continue
+		}
+		if only == nil && !step.enabled(opts, r) {
+			continue
+		}
+		if r.prefetched[step.name] {
+			r.markEnriched(step.name)
+			continue
+		}
+		// A replayed step that succeeds now is no longer failing
+		delete(r.EnrichmentErrors, step.name)
+		delete(r.statsPending, step.name)
+		err := step.run(env, r)
+		if errors.Is(err, errStopEnrichment) {
+			return nil
+		}
+		if err != nil {
+			failed = append(failed, enrichError{FullName: r.FullName, Endpoint: step.name, Error: err.Error(), transient: isTransient(ctx, err)})
+			if r.EnrichmentErrors == nil {
+				r.EnrichmentErrors = map[string]string{}
+			}
+			r.EnrichmentErrors[step.name] = err.Error()
+			continue
+		}
+		if !r.statsPending[step.name] {
+			r.markEnriched(step.name)
+		}
+	}
+	return failed
+}
+
+// markEnriched records that step ran to completion on r.
+func (r *outRepo) markEnriched(step string) {
+	if !slices.Contains(r.EnrichedSteps, step) {
+		r.EnrichedSteps = append(r.EnrichedSteps, step)
+	}
+}
+
+// transientStatusRe matches the status the fetchers put at the end of
+// their "... error NNN" messages.
+var transientStatusRe = regexp.MustCompile(`error (\d{3})$`)
+
+// isTransient reports whether a failed step is worth retrying: rate
+// limiting, server errors and transient network errors. Other 4xx, a 403
+// included, and responses that don't decode won't change on a retry.
+func isTransient(ctx context.Context, err error) bool {
+	if m := transientStatusRe.FindStringSubmatch(err.Error()); m != nil {
+		status, _ := strconv.Atoi(m[1])
+		return status >= 500 || status == 429
+	}
+	return isTransientNetError(ctx, err)
+}
+
+// logEnrichError reports a failed step at warn level; structured formats
+// get the repo, step and HTTP status (when there was one) as attributes.
+func logEnrichError(e enrichError) {
+	attrs := []interface{}{"repo", e.FullName, "step", e.Endpoint}
+	if m := transientStatusRe.FindStringSubmatch(e.Error); m != nil {
+		status, _ := strconv.Atoi(m[1])
+		attrs = append(attrs, "status", status)
+	}
+	attrs = append(attrs, "error", e.Error)
+	logger.Warn(fmt.Sprintf("  ⚠️  %s: %s failed: %s", e.FullName, e.Endpoint, e.Error), attrs...)
+}
+
+// enrichRepoWithRetries enriches r and, while any step failed transiently,
+// starts over up to --repo-retries more times. Each attempt starts from r
+// as it came in, so what GraphQL prefetched is kept. The failures of the
+// last attempt are returned.
+func enrichRepoWithRetries(ctx context.Context, client *http.Client, token string, opts options, r *outRepo) []enrichError {
+	// enrichRepo edits these maps in place
+	start := r.repoEnrichment
+	start.EnrichmentErrors = maps.Clone(start.EnrichmentErrors)
+	start.statsPending = maps.Clone(start.statsPending)
+	start.EnrichedSteps = slices.Clone(start.EnrichedSteps)
+
+	for attempt := 0; ; attempt++ {
+		failed := enrichRepo(ctx, client, token, opts, r, nil)
+		if attempt >= opts.RepoRetries || !slices.ContainsFunc(failed, func(e enrichError) bool { return e.transient }) {
+			return failed
+		}
+		wait := retryBackoff.wait(attempt + 1)
+		logWarnf("  ↻ %s: %d calls failed, retrying in %s", r.FullName, len(failed), wait.Round(time.Millisecond))
+		if sleepCtx(ctx, wait) != nil {
+			return failed
+		}
+		r.repoEnrichment = start
+		r.EnrichmentErrors = maps.Clone(start.EnrichmentErrors)
+		r.statsPending = maps.Clone(start.statsPending)
+		r.EnrichedSteps = slices.Clone(start.EnrichedSteps)
+	}
+}
+
+// defaultEnrichWorkers is the default --workers, the most repos enriched at
+// once; the pool runs fewer as the quota runs low. Past maxEnrichWorkers
+// concurrent requests GitHub's secondary rate limit kicks in.
+const (
+	defaultEnrichWorkers = 6
+	maxEnrichWorkers     = 32
+)
+
+// enrichAll enriches out[i] for each i in order, in that order, on a pool
+// of workers. Each worker writes only to the repo it took, so the only
+// shared state is the error list and the progress counter, both guarded
+// by mu. done, when set, is called with each repo as soon as it's finished
+// (or passed over after cancellation), from the worker that had it.
+func enrichAll(ctx context.Context, client *http.Client, token string, opts options, out []outRepo, order []int, progress *progressEmitter, done func(*outRepo)) []enrichError {
+	pool := newAdaptivePool(opts.Workers)
+	jobs := make(chan int, len(order))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var enrichErrors []enrichError
+
+	completed := 0
+	total := len(order)
+
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// Once cancelled, leave the remaining repos unenriched
+				if ctx.Err() != nil {
+					if done != nil {
+						done(&out[i])
+					}
+					continue
+				}
+				pool.acquire()
+				full := out[i].FullName
+				started := time.Now()
+				failed := enrichRepoWithRetries(ctx, client, token, opts, &out[i])
+				if opts.Timings {
+					out[i].EnrichDurationMs = int(time.Since(started).Milliseconds())
+				}
+				for _, e := range failed {
+					logEnrichError(e)
+				}
+				if done != nil {
+					done(&out[i])
+				}
+
+				mu.Lock()
+				enrichErrors = append(enrichErrors, failed...)
+				completed++
+				progress.emit(progressEvent{Event: "repo-enriched", Repo: full, Index: completed, Total: total})
+				if completed%5 == 0 || completed == total {
+					logInfof("  Progress: %d/%d repositories enriched", completed, total)
+				}
+				mu.Unlock()
+
+				// Pause between repos, longer as the quota runs low
+				sleepCtx(ctx, pool.release())
+			}
+		}()
+	}
+
+	for _, i := range order {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if ctx.Err() != nil {
+		logWarnf("⚠️  Stopped early (%v) after %d of %d repositories; writing what was collected", context.Cause(ctx), completed, total)
+	}
+	return enrichErrors
+}
+
+// Last commit + message, and the default branch HEAD it points at
+func enrichLastCommit(env *enrichEnv, r *outRepo) error {
+	last, err := fetchLastCommit(env.ctx, env.client, env.token, r.FullName)
+	if errors.Is(err, errRepoEmpty) {
+		// An empty repo may be mid-import; its stats would be misleadingly
+		// empty, so flag it for a later run and skip the rest.
+		status, found, ierr := fetchImportStatus(env.ctx, env.client, env.token, r.FullName)
+		if ierr == nil && found && importInProgress(status) {
+			r.ImportPending = true
+			return errStopEnrichment
+		}
+		r.IsEmpty = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r.LastCommitAt = localizeTimestamp(last.Date)
+	r.LastCommitMessage = last.Message
+	r.HeadSHA = last.SHA
+	return nil
+}
+
+// Newest commit across branches (opt-in). Runs after the commits step so
+// the default branch head it already knows isn't fetched again.
+func enrichLatestAnyBranch(env *enrichEnv, r *outRepo) error {
+	known, _ := time.Parse(time.RFC3339, r.LastCommitAt)
+	latest, err := fetchLatestCommitAnyBranch(env.ctx, env.client, env.token, r.FullName, env.opts.MaxBranches, r.HeadSHA, known)
+	if err != nil {
+		return err
+	}
+	if !latest.IsZero() {
+		r.LastCommitAtAnyBranch = formatTimestamp(latest)
+	}
+	return nil
+}
+
+// 52w activity stats. Repos not pushed to within --stats-only-if-pushed-within
+// would come back all zeros, so they get a zero array without the call.
+func enrichCommitActivity(env *enrichEnv, r *outRepo) error {
+	if days := env.opts.statsPushedWithinDays; days > 0 {
+		pushed, err := time.Parse(time.RFC3339, r.PushedAt)
+		if err == nil && time.Since(pushed) > time.Duration(days)*24*time.Hour {
+			r.WeeklyCommits52W = make([]int, 52)
+			r.StatsSkipped = true
+			return nil
+		}
+	}
+
+	weeks, pending, err := fetchCommitActivity52W(env.ctx, env.client, env.token, r.FullName)
+	if err != nil {
+		return err
+	}
+	r.WeeklyStats52W = weeks
+	r.markStatsPending("commit_activity", pending)
+
+	// Extract simple totals
+	totals := make([]int, len(weeks))
+	totalCommits := 0
+	for idx, w := range weeks {
+		totals[idx] = w.Total
+		totalCommits += w.Total
+	}
+	r.WeeklyCommits52W = totals
+	r.TotalCommits = totalCommits
+	if totalCommits > 0 && len(weeks) > 0 {
+		days := float64(len(weeks)*7) / float64(totalCommits)
+		r.AvgDaysBetweenCommits = math.Round(days*100) / 100
+	}
+	return nil
+}
+
+// Weekly additions/deletions (opt-in). Skipped along with the commit
+// activity for repos outside --stats-only-if-pushed-within.
+func enrichCodeFrequency(env *enrichEnv, r *outRepo) error {
+	if r.StatsSkipped {
+		return nil
+	}
+	weeks, pending, err := fetchCodeFrequency52W(env.ctx, env.client, env.token, r.FullName)
+	if err != nil {
+		return err
+	}
+	r.WeeklyCodeFrequency = weeks
+	r.markStatsPending("code_frequency", pending)
+	return nil
+}
+
+// Owner vs everyone weekly commits (opt-in), skipped like the commit activity
+func enrichParticipation(env *enrichEnv, r *outRepo) error {
+	if r.StatsSkipped {
+		return nil
+	}
+	p, pending, err := fetchParticipation(env.ctx, env.client, env.token, r.FullName)
+	if err != nil {
+		return err
+	}
+	r.ParticipationAll = p.All
+	r.ParticipationOwner = p.Owner
+	r.markStatsPending("participation", pending)
+	return nil
+}
+
+// All-time commit count (opt-in), skipped like the commit activity
+func enrichLifetimeCommits(env *enrichEnv, r *outRepo) error {
+	if r.StatsSkipped {
+		return nil
+	}
+	total, pending, err := fetchLifetimeCommits(env.ctx, env.client, env.token, r.FullName)
+	if err != nil {
+		return err
+	}
+	r.LifetimeCommits = total
+	r.markStatsPending("contributor_stats", pending)
+	return nil
+}
+
+// markStatsPending records that GitHub was still computing a stats step's
+// data, so its fields stay empty until a later run.
+func (r *outRepo) markStatsPending(step string, pending bool) {
+	if !pending {
+		return
+	}
+	r.StatsCachePending = true
+	if r.statsPending == nil {
+		r.statsPending = map[string]bool{}
+	}
+	r.statsPending[step] = true
+}
+
+// Language breakdown. A 404 here means the repo was deleted after it was
+// listed; drop whatever was fetched so far and stop.
+func enrichLanguages(env *enrichEnv, r *outRepo) error {
+	langs, err := fetchLanguages(env.ctx, env.client, env.token, r.FullName)
+	if errors.Is(err, errRepoNotFound) {
+		r.repoEnrichment = repoEnrichment{RepoDeleted: true}
+		return errStopEnrichment
+	}
+	if err != nil {
+		return err
+	}
+	if len(langs) > 0 {
+		r.LanguageBreakdown = langs
+		r.NonCodeRatio = nonCodeRatio(r.SizeKB, langs)
+	}
+	return nil
+}
+
+// nonCodeRatio estimates how much of a repo isn't code: 1 minus the share
+// of its size that linguist attributes to languages. Linguist skips
+// binaries and vendored files, so a ratio near 1 on a large repo usually
+// means large assets. The repo size includes history, so this is a rough
+// heuristic rather than a measurement.
+func nonCodeRatio(sizeKB int, langs map[string]int) float64 {
+	if sizeKB <= 0 {
+		return 0
+	}
+	code := 0
+	for _, n := range langs {
+		code += n
+	}
+	ratio := 1 - float64(code)/(float64(sizeKB)*1024)
+	return math.Round(math.Max(ratio, 0)*1000) / 1000
+}
+
+// Contributors (top N)
+func enrichContributors(env *enrichEnv, r *outRepo) error {
+	contribs, count, err := fetchContributors(env.ctx, env.client, env.token, r.FullName, env.opts.TopContributors, env.opts.ContributorPages)
+	if err != nil {
+		return err
+	}
+	r.TopContributors = contribs
+	r.ContributorCount = count
+	r.ContributionConcentration = contributionConcentration(contribs)
+	return nil
+}
+
+// contributionConcentration is the top contributor's share of the commits
+// of the listed contributors: 1 when a single person made them all, 0 when
+// there are none. Past --top-contributors the tail isn't counted, which
+// overstates the share of repos with many contributors.
+func contributionConcentration(contribs []contributor) float64 {
+	total, top := 0, 0
+	for _, c := range contribs {
+		total += c.Contributions
+		top = max(top, c.Contributions)
+	}
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(top)/float64(total)*1000) / 1000
+}
+
+// Community profile (health %, CoC, contributing)
+func enrichCommunity(env *enrichEnv, r *outRepo) error {
+	profile, found, err := fetchCommunityProfile(env.ctx, env.client, env.token, r.FullName)
+	if err != nil || !found {
+		return err
+	}
+	r.HasCommunityProfile = true
+	r.CommunityHealthPercentage = profile.HealthPercentage
+	r.HasCodeOfConduct = profile.Files.CodeOfConduct != nil
+	r.HasContributing = profile.Files.Contributing != nil
+	return nil
+}
+
+// Open pull request count (opt-in), to tell issues from PRs in open_issues
+func enrichOpenPRCount(env *enrichEnv, r *outRepo) error {
+	n, err := fetchOpenPRCount(env.ctx, env.client, env.token, r.FullName)
+	if err != nil {
+		return err
+	}
+	r.OpenPRs = n
+	r.OpenIssuesOnly = max(r.OpenIssues-n, 0)
+	return nil
+}
+
+// Latest release (opt-in)
+func enrichLatestRelease(env *enrichEnv, r *outRepo) error {
+	rel, found, err := fetchLatestRelease(env.ctx, env.client, env.token, r.FullName)
+	if err != nil || !found {
+		return err
+	}
+	r.LatestReleaseTag = rel.TagName
+	r.LatestReleaseName = rel.Name
+	r.LatestReleaseAt = localizeTimestamp(rel.PublishedAt)
+	return nil
+}
+
+// Issue close ratio via search (opt-in, search quota)
+func enrichIssueRatio(env *enrichEnv, r *outRepo) error {
+	open, closed, err := fetchIssueCounts(env.ctx, env.client, env.token, r.FullName)
+	if err != nil {
+		return err
+	}
+	if open+closed > 0 {
+		r.IssuesTotal = open + closed
+		r.IssueCloseRatio = float64(closed) / float64(open+closed)
+		r.HighOpenIssueRatio = float64(open)/float64(open+closed) >= highOpenIssueRatio
+	}
+	return nil
+}
+
+// Merge settings (opt-in)
+func enrichMergeSettings(env *enrichEnv, r *outRepo) error {
+	details, err := env.repoDetails(r.FullName)
+	if err != nil {
+		return err
+	}
+	if details.AllowMergeCommit == nil {
+		return nil
+	}
+	r.MergeSettingsKnown = true
+	r.AllowSquashMerge = boolValue(details.AllowSquashMerge)
+	r.AllowMergeCommit = boolValue(details.AllowMergeCommit)
+	r.AllowRebaseMerge = boolValue(details.AllowRebaseMerge)
+	r.DeleteBranchOnMerge = boolValue(details.DeleteBranchOnMerge)
+	return nil
+}
+
+// Branch count and default branch protection (opt-in)
+func enrichBranches(env *enrichEnv, r *outRepo) error {
+	count, err := fetchBranchCount(env.ctx, env.client, env.token, r.FullName)
+	if err != nil {
+		return err
+	}
+	r.BranchCount = count
+	if count == 0 || r.DefaultBranch == "" {
+		return nil
+	}
+
+	protected, known, err := fetchBranchProtected(env.ctx, env.client, env.token, r.FullName, r.DefaultBranch)
+	if err != nil {
+		return err
+	}
+	r.DefaultBranchProtectionKnown = known
+	r.DefaultBranchProtected = protected
+	return nil
+}
+
+// Fork behind/ahead of upstream (opt-in)
+func enrichForkCompare(env *enrichEnv, r *outRepo) error {
+	details, err := env.repoDetails(r.FullName)
+	if err != nil {
+		return err
+	}
+	cmp, ok, err := fetchForkComparison(env.ctx, env.client, env.token, r.FullName, r.DefaultBranch, details)
+	if err != nil || !ok {
+		return err
+	}
+	r.ForkParent = cmp.Parent
+	r.ForkBehindBy = cmp.BehindBy
+	r.ForkAheadBy = cmp.AheadBy
+	return nil
+}
+
+// Open pull request authors (opt-in)
+func enrichPRAuthors(env *enrichEnv, r *outRepo) error {
+	authors, external, err := fetchOpenPRAuthors(env.ctx, env.client, env.token, r.FullName)
+	if err != nil {
+		return err
+	}
+	r.OpenPRAuthors = authors
+	r.ExternalPRAuthors = external
+	return nil
+}
+
+// CI providers from config files (opt-in)
+func enrichCIProviders(env *enrichEnv, r *outRepo) error {
+	providers, err := fetchCIProviders(env.ctx, env.client, env.token, r.FullName)
+	if err != nil {
+		return err
+	}
+	r.CIProviders = providers
+	return nil
+}
+
+// Default workflow token permissions (opt-in, admin only)
+func enrichWorkflowPermissions(env *enrichEnv, r *outRepo) error {
+	perm, known, err := fetchWorkflowPermissions(env.ctx, env.client, env.token, r.FullName)
+	if err != nil || !known {
+		return err
+	}
+	r.DefaultWorkflowPermissions = perm
+	return nil
+}
+
+// Workflow run count and latest outcome (opt-in)
+func enrichWorkflowRuns(env *enrichEnv, r *outRepo) error {
+	count, latest, err := fetchWorkflowRuns(env.ctx, env.client, env.token, r.FullName)
+	if err != nil {
+		return err
+	}
+	r.WorkflowRunCount = count
+	r.LatestWorkflowConclusion = latest
+	return nil
+}
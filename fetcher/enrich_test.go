@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// discardLogs silences the run's logging for the length of the test.
+func discardLogs(t *testing.T) {
+	oldLogger := logger
+	logger = slog.New(newHumanHandler(io.Discard, slog.LevelInfo))
+	t.Cleanup(func() { logger = oldLogger })
+}
+
+// newTestAPI points every endpoint at an httptest server running h, with
+// no pause between repos and logging discarded, for the length of the test.
+func newTestAPI(t *testing.T, h http.Handler) *httptest.Server {
+	t.Helper()
+	discardLogs(t)
+	srv := httptest.NewServer(h)
+	oldBase, oldDelay := baseURL, requestDelay
+	baseURL, requestDelay = srv.URL, 0
+	t.Cleanup(func() {
+		srv.Close()
+		baseURL, requestDelay = oldBase, oldDelay
+	})
+	return srv
+}
+
+func TestEnrichAllConcurrent(t *testing.T) {
+	var calls atomic.Int64
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		p := r.URL.Path
+		switch {
+		case strings.HasSuffix(p, "/commits"):
+			fmt.Fprintf(w, `[{"sha":"abc","commit":{"author":{"date":"2024-01-02T03:04:05Z"},"message":"commit to %s"}}]`, p)
+		case strings.HasSuffix(p, "/stats/commit_activity"):
+			fmt.Fprint(w, `[{"total":3,"w":1700000000,"days":[0,1,2,0,0,0,0]},{"total":4,"w":1700604800,"days":[4,0,0,0,0,0,0]}]`)
+		case strings.HasSuffix(p, "/languages"):
+			fmt.Fprint(w, `{"Go":1000,"Shell":24}`)
+		case strings.HasSuffix(p, "/contributors"):
+			fmt.Fprint(w, `[{"login":"a","contributions":5},{"login":"b","contributions":2}]`)
+		case strings.HasSuffix(p, "/community/profile"):
+			fmt.Fprint(w, `{"health_percentage":42,"files":{"contributing":{}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	opts, err := parseOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.RepoRetries = 0
+
+	const n = 300
+	out := make([]outRepo, n)
+	order := make([]int, n)
+	for i := range out {
+		out[i] = outRepo{FullName: fmt.Sprintf("o/r%d", i)}
+		order[i] = i
+	}
+
+	var doneCount atomic.Int64
+	failed := enrichAll(context.Background(), http.DefaultClient, "token", opts, out, order, nil, func(*outRepo) { doneCount.Add(1) })
+	if len(failed) > 0 {
+		t.Fatalf("enrichAll failed %d steps, first: %+v", len(failed), failed[0])
+	}
+	if got := doneCount.Load(); got != n {
+		t.Errorf("done called %d times, want %d", got, n)
+	}
+
+	for _, r := range out {
+		if r.LastCommitAt == "" || !strings.Contains(r.LastCommitMessage, "/repos/"+r.FullName+"/") {
+			t.Errorf("%s: last commit %q %q, not its own", r.FullName, r.LastCommitAt, r.LastCommitMessage)
+		}
+		if r.TotalCommits != 7 || len(r.WeeklyCommits52W) != 2 {
+			t.Errorf("%s: total commits %d over %d weeks, want 7 over 2", r.FullName, r.TotalCommits, len(r.WeeklyCommits52W))
+		}
+		if r.LanguageBreakdown["Go"] != 1000 {
+			t.Errorf("%s: languages %v", r.FullName, r.LanguageBreakdown)
+		}
+		if r.ContributorCount != 2 || len(r.TopContributors) != 2 {
+			t.Errorf("%s: %d contributors, top %v", r.FullName, r.ContributorCount, r.TopContributors)
+		}
+		if r.CommunityHealthPercentage != 42 || !r.HasContributing {
+			t.Errorf("%s: community %d contributing=%v", r.FullName, r.CommunityHealthPercentage, r.HasContributing)
+		}
+	}
+	if calls.Load() == 0 {
+		t.Error("no requests reached the server")
+	}
+}
+
+func TestEnrichRepoOnlyRunsDisabledSteps(t *testing.T) {
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/stats/code_frequency") {
+			t.Errorf("unexpected request for %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[[1700000000,10,-4]]`)
+	}))
+
+	opts, err := parseOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := outRepo{FullName: "o/r"}
+	r.EnrichmentErrors = map[string]string{"code_frequency": "code_frequency error 502"}
+
+	// --code-frequency is off in this run, but the replayed step was on in the run that failed
+	failed := enrichRepo(context.Background(), http.DefaultClient, "token", opts, &r, map[string]bool{"code_frequency": true})
+	if len(failed) > 0 {
+		t.Fatalf("failed: %+v", failed)
+	}
+	if len(r.WeeklyCodeFrequency) != 1 || r.WeeklyCodeFrequency[0].Deletions != 4 {
+		t.Errorf("code frequency %+v, want the replayed week", r.WeeklyCodeFrequency)
+	}
+	if len(r.EnrichmentErrors) > 0 {
+		t.Errorf("errors left %v", r.EnrichmentErrors)
+	}
+}
+
+func TestEnrichRepoWithRetries(t *testing.T) {
+	oldBackoff := retryBackoff
+	retryBackoff = backoffPolicy{base: time.Millisecond, factor: 1, max: time.Millisecond}
+	t.Cleanup(func() { retryBackoff = oldBackoff })
+
+	tests := []struct {
+		name      string
+		first     string // body of the first languages response
+		status    int
+		wantCalls int64
+		wantErr   bool
+	}{
+		{"server error is retried", "", 502, 2, false},
+		{"rate limit is retried", "", 429, 2, false},
+		{"forbidden is not", "", 403, 1, true},
+		{"bad body is not", "{", 200, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int64
+			newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, "/languages") {
+					http.NotFound(w, r)
+					return
+				}
+				if calls.Add(1) == 1 {
+					w.WriteHeader(tt.status)
+					fmt.Fprint(w, tt.first)
+					return
+				}
+				fmt.Fprint(w, `{"Go":100}`)
+			}))
+
+			opts, err := parseOptions(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts.RepoRetries = 2
+			opts.EnrichStats, opts.EnrichContributors = false, false
+
+			// GraphQL already gave the last commit
+			r := outRepo{FullName: "o/r"}
+			r.LastCommitAt = "2024-01-02T03:04:05Z"
+			r.prefetched = map[string]bool{"commits": true}
+
+			failed := enrichRepoWithRetries(context.Background(), http.DefaultClient, "token", opts, &r)
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("%d languages calls, want %d", got, tt.wantCalls)
+			}
+			if (len(failed) > 0) != tt.wantErr {
+				t.Errorf("failed %+v, want error %v", failed, tt.wantErr)
+			}
+			if r.LastCommitAt == "" || !r.prefetched["commits"] {
+				t.Errorf("prefetched last commit lost: %q %v", r.LastCommitAt, r.prefetched)
+			}
+			if !tt.wantErr && (r.LanguageBreakdown["Go"] != 100 || len(r.EnrichmentErrors) > 0) {
+				t.Errorf("languages %v, errors %v after the retry", r.LanguageBreakdown, r.EnrichmentErrors)
+			}
+		})
+	}
+}
+
+func TestEnrichSwitchesTurnOffDefaultCalls(t *testing.T) {
+	var paths []string
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		http.NotFound(w, r)
+	}))
+
+	opts, err := parseOptions([]string{"--enrich-commits=false", "--enrich-stats=false", "--enrich-languages=false", "--enrich-contributors=false", "--enrich-community=false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := outRepo{FullName: "o/r"}
+	if failed := enrichRepo(context.Background(), http.DefaultClient, "token", opts, &r, nil); len(failed) > 0 {
+		t.Errorf("failed: %+v", failed)
+	}
+	if len(paths) > 0 {
+		t.Errorf("requested %v with every default enrichment off", paths)
+	}
+}
+
+func TestEnrichRepoRecordsEnrichedSteps(t *testing.T) {
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/languages") {
+			http.Error(w, "boom", http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+
+	opts, err := parseOptions([]string{"--enrich-stats=false", "--enrich-community=false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := outRepo{FullName: "o/r"}
+	enrichRepo(context.Background(), http.DefaultClient, "token", opts, &r, nil)
+	// languages failed and stats and community were off
+	if want := []string{"commits", "contributors"}; !slices.Equal(r.EnrichedSteps, want) {
+		t.Errorf("enriched steps %v, want %v", r.EnrichedSteps, want)
+	}
+}
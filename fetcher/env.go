@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+// defaultEnvFile is the dotenv file read when --env-file isn't given. Unlike
+// an explicit --env-file, it may be missing.
+const defaultEnvFile = ".env"
+
+// loadEnvFile loads the dotenv file and reports where GITHUB_TOKEN ends up
+// coming from. By default variables already in the environment win over
+// the file; with override the file wins.
+func loadEnvFile(path string, override bool) (tokenSource string, err error) {
+	fromFile, err := godotenv.Read(path)
+	if errors.Is(err, fs.ErrNotExist) && path == defaultEnvFile {
+		fromFile, err = nil, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("--env-file: %w", err)
+	}
+
+	inEnv := os.Getenv("GITHUB_TOKEN") != ""
+	if fromFile != nil {
+		if override {
+			err = godotenv.Overload(path)
+		} else {
+			err = godotenv.Load(path)
+		}
+		if err != nil {
+			return "", fmt.Errorf("--env-file: %w", err)
+		}
+	}
+
+	_, inFile := fromFile["GITHUB_TOKEN"]
+	switch {
+	case inFile && (override || !inEnv):
+		return path, nil
+	case inFile:
+		return "the environment (shadowing " + path + "; --env-overrides prefers the file)", nil
+	case inEnv:
+		return "the environment", nil
+	default:
+		return "", nil
+	}
+}
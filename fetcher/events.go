@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+type ghEvent struct {
+	Type string `json:"type"`
+	Repo struct {
+		Name string `json:"name"`
+	} `json:"repo"`
+	CreatedAt string `json:"created_at"`
+}
+
+// eventsMaxPages bounds the events listing; GitHub serves at most 300
+// events (3 pages of 100) anyway.
+const eventsMaxPages = 3
+
+// fetchRecentPushes returns, for each repo the user pushed to after since,
+// the time of the latest such push.
+func fetchRecentPushes(ctx context.Context, client *http.Client, token, login string, since time.Time) (map[string]time.Time, error) {
+	pushes := map[string]time.Time{}
+	for page := 1; page <= eventsMaxPages; page++ {
+		url := apiURL(fmt.Sprintf("/users/%s/events?per_page=100&page=%d", login, page))
+		status, body, err := doGET(ctx, client, url, token)
+		if err != nil {
+			return nil, err
+		}
+		if status < 200 || status >= 300 {
+			return nil, fmt.Errorf("events error %d", status)
+		}
+
+		var events []ghEvent
+		if err := json.Unmarshal(body, &events); err != nil {
+			return nil, err
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		reachedSince := false
+		for _, ev := range events {
+			t, err := time.Parse(time.RFC3339, ev.CreatedAt)
+			if err != nil {
+				continue
+			}
+			if !t.After(since) {
+				reachedSince = true
+				continue
+			}
+			if ev.Type == "PushEvent" && t.After(pushes[ev.Repo.Name]) {
+				pushes[ev.Repo.Name] = t
+			}
+		}
+		// Events are newest first, so older pages hold nothing new
+		if reachedSince {
+			break
+		}
+	}
+	return pushes, nil
+}
+
+// runEventsMode is the lightweight alternative to a full run: it reads the
+// user's recent events, and for repos pushed to since the last summary was
+// generated, refreshes just their push time and last-commit info.
+func runEventsMode(ctx context.Context, client *http.Client, token string, opts options, me ghUser) error {
+	if me.Login == "" {
+		return fmt.Errorf("events mode needs the authenticated user's login")
+	}
+
+	out, err := loadPreviousIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return fmt.Errorf("no existing index at %s to update; run a full enrichment first", indexPath)
+	}
+
+	var prev summary
+	if data, err := os.ReadFile(summaryPath); err == nil {
+		_ = json.Unmarshal(data, &prev)
+	}
+	since, err := time.Parse(time.RFC3339, prev.GeneratedAt)
+	if err != nil {
+		return fmt.Errorf("%s has no usable generated_at to poll from", summaryPath)
+	}
+
+	logInfof("📡 Checking events for %s since %s...", me.Login, prev.GeneratedAt)
+	pushes, err := fetchRecentPushes(ctx, client, token, me.Login, since)
+	if err != nil {
+		return err
+	}
+
+	updated := 0
+	for i := range out {
+		t, ok := pushes[out[i].FullName]
+		if !ok {
+			continue
+		}
+		out[i].PushedAt = formatTimestamp(t)
+		enrichRepo(ctx, client, token, opts, &out[i], map[string]bool{"commits": true})
+		updated++
+		logDebugf("  ↻ %s", out[i].FullName)
+	}
+
+	rankFreshness(out)
+	applyContentHashes(out, opts.Hash)
+	sum := buildSummary(out, opts)
+	sum.Owner = prev.Owner
+
+	indexJSON, _ := json.MarshalIndent(out, "", "  ")
+	summaryJSON, _ := json.MarshalIndent(sum, "", "  ")
+	if err := os.WriteFile(indexPath, indexJSON, 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(summaryPath, summaryJSON, 0644); err != nil {
+		return err
+	}
+
+	logInfof("✓ %d repositories pushed to since the last run, %d updated", len(pushes), updated)
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// parseFieldList validates a --fields value against the outRepo JSON keys.
+// enriched_steps isn't one: it vouches for the whole record, so a projected
+// index must not carry it or a later run would take it as complete.
+func parseFieldList(spec string) ([]string, error) {
+	valid := map[string]bool{}
+	var names []string
+	for _, n := range jsonFieldNames(reflect.TypeOf(outRepo{})) {
+		if n != "enriched_steps" {
+			valid[n] = true
+			names = append(names, n)
+		}
+	}
+
+	var fields []string
+	seen := map[string]bool{}
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		if !valid[f] {
+			return nil, fmt.Errorf("unknown field %q; valid fields are: %s", f, strings.Join(names, ", "))
+		}
+		seen[f] = true
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields given")
+	}
+	return fields, nil
+}
+
+// projectFields reduces each repo to the given JSON keys, kept in the order
+// they were asked for.
+func projectFields(out []outRepo, fields []string) ([]json.RawMessage, error) {
+	projected := make([]json.RawMessage, 0, len(out))
+	for _, r := range out {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(data, &all); err != nil {
+			return nil, err
+		}
+
+		var b bytes.Buffer
+		b.WriteByte('{')
+		for i, f := range fields {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			key, _ := json.Marshal(f)
+			b.Write(key)
+			b.WriteByte(':')
+			if v, ok := all[f]; ok {
+				b.Write(v)
+			} else {
+				// omitempty field that was empty
+				b.WriteString("null")
+			}
+		}
+		b.WriteByte('}')
+		projected = append(projected, b.Bytes())
+	}
+	return projected, nil
+}
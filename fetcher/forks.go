@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type forkComparison struct {
+	Parent   string
+	BehindBy int
+	AheadBy  int
+}
+
+// fetchForkComparison compares a fork's branch against its parent's default
+// branch, using the parent from the fork's repo details. ok is false when
+// the parent can't be resolved (deleted, or made private) or the compare
+// isn't possible, which isn't treated as an error.
+func fetchForkComparison(ctx context.Context, client *http.Client, token, fullName, branch string, details ghRepoDetails) (forkComparison, bool, error) {
+	if details.Parent == nil || details.Parent.DefaultBranch == "" {
+		return forkComparison{}, false, nil
+	}
+
+	owner := details.Parent.Owner.Login
+	if owner == "" {
+		owner = ownerOf(details.Parent.FullName)
+	}
+	base := owner + ":" + details.Parent.DefaultBranch
+	u := apiURL(fmt.Sprintf("/repos/%s/compare/%s...%s",
+		fullName, url.PathEscape(base), url.PathEscape(branch)))
+	status, body, err := doGET(ctx, client, u, token)
+	if err != nil {
+		return forkComparison{}, false, err
+	}
+	if status == 404 {
+		return forkComparison{}, false, nil
+	}
+	if status < 200 || status >= 300 {
+		return forkComparison{}, false, fmt.Errorf("compare error %d", status)
+	}
+
+	var cmp struct {
+		AheadBy  int `json:"ahead_by"`
+		BehindBy int `json:"behind_by"`
+	}
+	if err := json.Unmarshal(body, &cmp); err != nil {
+		return forkComparison{}, false, err
+	}
+	return forkComparison{
+		Parent:   details.Parent.FullName,
+		BehindBy: cmp.BehindBy,
+		AheadBy:  cmp.AheadBy,
+	}, true, nil
+}
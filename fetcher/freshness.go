@@ -0,0 +1,28 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// rankFreshness sets FreshnessRank to each repo's position by pushed_at,
+// 1 being the most recently pushed. Ties, and repos without a push time
+// (ranked last), are ordered by full name so the ranks are stable.
+func rankFreshness(out []outRepo) {
+	pushed := make([]time.Time, len(out))
+	idx := make([]int, len(out))
+	for i := range out {
+		pushed[i], _ = time.Parse(time.RFC3339, out[i].PushedAt)
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		ta, tb := pushed[idx[a]], pushed[idx[b]]
+		if !ta.Equal(tb) {
+			return ta.After(tb)
+		}
+		return out[idx[a]].FullName < out[idx[b]].FullName
+	})
+	for rank, i := range idx {
+		out[i].FreshnessRank = rank + 1
+	}
+}
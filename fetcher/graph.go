@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+)
+
+// graphNode and graphEdge are the node-link layout that d3-force, Gephi's
+// JSON importer and networkx.node_link_graph all read.
+type graphNode struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Type  string `json:"type"` // "repo" or "contributor"
+}
+
+type graphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Weight int    `json:"weight"`
+}
+
+type contributorGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// buildContributorGraph links every repo to its top contributors, weighted
+// by their contributions. Nodes are prefixed by type because a user and a
+// repo can share a name. Repos without contributor data are left out.
+func buildContributorGraph(out []outRepo) contributorGraph {
+	g := contributorGraph{Nodes: []graphNode{}, Edges: []graphEdge{}}
+	people := map[string]bool{}
+	for _, r := range out {
+		if len(r.TopContributors) == 0 {
+			continue
+		}
+		repoID := "repo:" + r.FullName
+		g.Nodes = append(g.Nodes, graphNode{ID: repoID, Label: r.FullName, Type: "repo"})
+		for _, c := range r.TopContributors {
+			userID := "user:" + c.Login
+			if !people[c.Login] {
+				people[c.Login] = true
+				g.Nodes = append(g.Nodes, graphNode{ID: userID, Label: c.Login, Type: "contributor"})
+			}
+			g.Edges = append(g.Edges, graphEdge{Source: repoID, Target: userID, Weight: c.Contributions})
+		}
+	}
+	sort.SliceStable(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	return g
+}
+
+func writeContributorGraph(path string, out []outRepo) error {
+	data, err := json.MarshalIndent(buildContributorGraph(out), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
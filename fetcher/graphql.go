@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string        `json:"message"`
+		Path    []interface{} `json:"path"`
+	} `json:"errors"`
+}
+
+// graphqlFieldErrors reports the top-level fields of a query that failed
+// while the others succeeded, e.g. the one deleted repository of a batch:
+// the error message by field alias. The data of the others is decoded.
+type graphqlFieldErrors map[string]string
+
+func (e graphqlFieldErrors) Error() string {
+	aliases := make([]string, 0, len(e))
+	for alias := range e {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return fmt.Sprintf("graphql: %d fields failed, %s: %s", len(e), aliases[0], e[aliases[0]])
+}
+
+// doGraphQL runs a query against the GraphQL v4 API and decodes its data
+// payload into out. Errors raised for some top-level fields only are
+// returned as graphqlFieldErrors, with out holding the rest; any other
+// error fails the whole query.
+func doGraphQL(ctx context.Context, client *http.Client, token, query string, variables map[string]interface{}, out interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+
+	url := graphqlURL()
+	status, _, body, err := sendWithRetries(ctx, url, "graphql", func() (int, http.Header, []byte, error) {
+		return postGraphQLOnce(ctx, client, url, token, payload)
+	})
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("graphql error %d", status)
+	}
+
+	var gr graphqlResponse
+	if err := json.Unmarshal(body, &gr); err != nil {
+		return err
+	}
+	if len(gr.Errors) == 0 {
+		return json.Unmarshal(gr.Data, out)
+	}
+
+	fieldErrs := graphqlFieldErrors{}
+	noData := len(gr.Data) == 0 || string(gr.Data) == "null"
+	for _, e := range gr.Errors {
+		var alias string
+		if len(e.Path) > 0 {
+			alias, _ = e.Path[0].(string)
+		}
+		if alias == "" || noData {
+			return fmt.Errorf("graphql: %s", e.Message)
+		}
+		if _, seen := fieldErrs[alias]; !seen {
+			fieldErrs[alias] = e.Message
+		}
+	}
+	if err := json.Unmarshal(gr.Data, out); err != nil {
+		return err
+	}
+	return fieldErrs
+}
+
+// postGraphQLOnce sends one GraphQL request and records the quota it
+// reports. GraphQL refuses a query over its rate limit with a 200 and a
+// RATE_LIMITED error; that is passed on as a 403, so sendWithRetries
+// waits for the reset as it would for REST.
+func postGraphQLOnce(ctx context.Context, client *http.Client, url, token string, payload []byte) (int, http.Header, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+	recordRateLimit(resp.Header)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, resp.Header, nil, err
+	}
+	status := resp.StatusCode
+	if status == http.StatusOK && bytes.Contains(body, []byte(`"RATE_LIMITED"`)) {
+		status = http.StatusForbidden
+	}
+	return status, resp.Header, body, nil
+}
+
+// repositoryBatchQuery builds one query looking up fields on each repo of
+// batch, aliased r0, r1, ...; aliases maps each alias back to its repo.
+func repositoryBatchQuery(batch []string, fields string) (query string, vars map[string]interface{}, aliases map[string]string) {
+	var decls, body strings.Builder
+	vars = map[string]interface{}{}
+	aliases = make(map[string]string, len(batch))
+	for i, full := range batch {
+		owner, name, _ := strings.Cut(full, "/")
+		vars[fmt.Sprintf("o%d", i)] = owner
+		vars[fmt.Sprintf("n%d", i)] = name
+		aliases[fmt.Sprintf("r%d", i)] = full
+		fmt.Fprintf(&decls, "$o%d: String!, $n%d: String!, ", i, i)
+		fmt.Fprintf(&body, "r%d: repository(owner: $o%d, name: $n%d) { %s }\n", i, i, i, fields)
+	}
+	query = fmt.Sprintf("query(%s) {\n%s}", strings.TrimSuffix(decls.String(), ", "), body.String())
+	return query, vars, aliases
+}
+
+// logFieldErrors warns about each repo of a batch whose lookup failed, and
+// reports whether err was just that, i.e. the rest of the batch is good.
+func logFieldErrors(err error, aliases map[string]string, what string) bool {
+	var fieldErrs graphqlFieldErrors
+	if !errors.As(err, &fieldErrs) {
+		return false
+	}
+	for alias, msg := range fieldErrs {
+		logWarnf("⚠️  GraphQL %s failed for %s, using REST for it: %s", what, cmp.Or(aliases[alias], alias), msg)
+	}
+	return true
+}
+
+// probeGraphQL checks that the GraphQL endpoint answers a trivial query.
+// Environments without GraphQL (404) or tokens not allowed to use it (401,
+// 403) fail here once, so callers can fall back to REST up front.
+func probeGraphQL(ctx context.Context, client *http.Client, token string) error {
+	var data struct {
+		Viewer struct {
+			Login string `json:"login"`
+		} `json:"viewer"`
+	}
+	return doGraphQL(ctx, client, token, "query { viewer { login } }", nil, &data)
+}
+
+// graphqlAvailable reports whether this run can use GraphQL. It may be
+// missing (some Enterprise setups, restricted tokens), so it's probed once
+// rather than failing every GraphQL call, and only when a feature needing
+// it is on: the contribution calendar, --graphql-engagement or
+// --api=graphql. Without one, the run makes no GraphQL call at all.
+func graphqlAvailable(ctx context.Context, client *http.Client, token string, opts options, calendar bool) bool {
+	if !calendar && !opts.GraphQLEngagement && opts.API != "graphql" {
+		return false
+	}
+	if err := probeGraphQL(ctx, client, token); err != nil {
+		logWarnf("⚠️  GraphQL unavailable (%v); using REST only, contribution calendar skipped", err)
+		return false
+	}
+	return true
+}
+
+type contributionCalendar struct {
+	TotalContributions int
+	CurrentStreakDays  int
+	LongestStreakDays  int
+}
+
+const contributionCalendarQuery = `query {
+  viewer {
+    contributionsCollection {
+      contributionCalendar {
+        totalContributions
+        weeks { contributionDays { date contributionCount } }
+      }
+    }
+  }
+}`
+
+// fetchContributionCalendar returns the authenticated user's contribution
+// total for the past year, plus the streaks derived from the daily counts.
+func fetchContributionCalendar(ctx context.Context, client *http.Client, token string) (contributionCalendar, error) {
+	var data struct {
+		Viewer struct {
+			ContributionsCollection struct {
+				ContributionCalendar struct {
+					TotalContributions int `json:"totalContributions"`
+					Weeks              []struct {
+						ContributionDays []struct {
+							Date              string `json:"date"`
+							ContributionCount int    `json:"contributionCount"`
+						} `json:"contributionDays"`
+					} `json:"weeks"`
+				} `json:"contributionCalendar"`
+			} `json:"contributionsCollection"`
+		} `json:"viewer"`
+	}
+	if err := doGraphQL(ctx, client, token, contributionCalendarQuery, nil, &data); err != nil {
+		return contributionCalendar{}, err
+	}
+
+	cal := data.Viewer.ContributionsCollection.ContributionCalendar
+	var counts []int
+	for _, w := range cal.Weeks {
+		for _, d := range w.ContributionDays {
+			counts = append(counts, d.ContributionCount)
+		}
+	}
+
+	current, longest := contributionStreaks(counts)
+	return contributionCalendar{
+		TotalContributions: cal.TotalContributions,
+		CurrentStreakDays:  current,
+		LongestStreakDays:  longest,
+	}, nil
+}
+
+// contributionStreaks derives the current and longest runs of days with at
+// least one contribution from a chronological list of daily counts. Today
+// not having a contribution yet doesn't break the current streak.
+func contributionStreaks(counts []int) (current, longest int) {
+	run := 0
+	for _, c := range counts {
+		if c > 0 {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+
+	end := len(counts) - 1
+	if end >= 0 && counts[end] == 0 {
+		end--
+	}
+	for i := end; i >= 0 && counts[i] > 0; i-- {
+		current++
+	}
+	return current, longest
+}
+
+type engagementCounts struct {
+	Stars    int
+	Watchers int
+}
+
+// graphqlBatchSize is how many repositories are looked up per aliased
+// GraphQL query.
+const graphqlBatchSize = 50
+
+// fetchGraphQLEngagement looks up stargazer and watcher counts for the
+// given repos through GraphQL. Unlike REST, where watchers_count is just a
+// legacy alias of stargazers_count, watchers.totalCount is the number of
+// people actually watching the repo. The counts of the batches that worked
+// are returned even when others failed, with the errors of those; repos
+// without counts keep their REST ones.
+func fetchGraphQLEngagement(ctx context.Context, client *http.Client, token string, fullNames []string) (map[string]engagementCounts, error) {
+	counts := make(map[string]engagementCounts, len(fullNames))
+	var errs []error
+	for start := 0; start < len(fullNames); start += graphqlBatchSize {
+		batch := fullNames[start:min(start+graphqlBatchSize, len(fullNames))]
+
+		query, vars, aliases := repositoryBatchQuery(batch, "stargazerCount watchers { totalCount }")
+		var data map[string]*struct {
+			StargazerCount int `json:"stargazerCount"`
+			Watchers       struct {
+				TotalCount int `json:"totalCount"`
+			} `json:"watchers"`
+		}
+		if err := doGraphQL(ctx, client, token, query, vars, &data); err != nil && !logFieldErrors(err, aliases, "engagement") {
+			errs = append(errs, fmt.Errorf("batch of %d repos from %s: %w", len(batch), batch[0], err))
+			continue
+		}
+		// keyed by the name asked for: a renamed repo answers with its new one
+		for alias, repo := range data {
+			full, ok := aliases[alias]
+			if repo == nil || !ok {
+				continue
+			}
+			counts[full] = engagementCounts{Stars: repo.StargazerCount, Watchers: repo.Watchers.TotalCount}
+		}
+	}
+	return counts, errors.Join(errs...)
+}
+
+// graphqlRepoData is what --api=graphql fetches per repo in place of the
+// REST commits and languages calls.
+type graphqlRepoData struct {
+	Languages struct {
+		Edges []struct {
+			Size int `json:"size"`
+			Node struct {
+				Name string `json:"name"`
+			} `json:"node"`
+		} `json:"edges"`
+	} `json:"languages"`
+	DefaultBranchRef *struct {
+		Target struct {
+			OID          string `json:"oid"`
+			AuthoredDate string `json:"authoredDate"`
+			Message      string `json:"message"`
+		} `json:"target"`
+	} `json:"defaultBranchRef"`
+}
+
+const graphqlRepoFields = `languages(first: 100, orderBy: {field: SIZE, direction: DESC}) { edges { size node { name } } }
+defaultBranchRef { target { ... on Commit { oid authoredDate message } } }`
+
+// fetchGraphQLRepoData fetches the languages and default-branch head of
+// many repos, graphqlBatchSize per query. A repo whose lookup fails (e.g.
+// because it was deleted) falls back to REST, and so do all the repos of a
+// batch that fails as a whole.
+func fetchGraphQLRepoData(ctx context.Context, client *http.Client, token string, fullNames []string) map[string]graphqlRepoData {
+	result := make(map[string]graphqlRepoData, len(fullNames))
+	for start := 0; start < len(fullNames); start += graphqlBatchSize {
+		batch := fullNames[start:min(start+graphqlBatchSize, len(fullNames))]
+
+		query, vars, aliases := repositoryBatchQuery(batch, graphqlRepoFields)
+
+		var data map[string]*graphqlRepoData
+		if err := doGraphQL(ctx, client, token, query, vars, &data); err != nil && !logFieldErrors(err, aliases, "lookup") {
+			logWarnf("⚠️  GraphQL batch of %d repos failed, using REST for them: %v", len(batch), err)
+			continue
+		}
+		for alias, repo := range data {
+			if full, ok := aliases[alias]; ok && repo != nil {
+				result[full] = *repo
+			}
+		}
+	}
+	return result
+}
+
+// applyGraphQLRepoData fills the fields the REST commits and languages
+// steps would, the same way they do, and marks those steps as done. An
+// empty repo has no head commit; its commits step still runs over REST,
+// which is where the import check lives.
+func applyGraphQLRepoData(r *outRepo, d graphqlRepoData) {
+	langs := map[string]int{}
+	for _, e := range d.Languages.Edges {
+		langs[e.Node.Name] = e.Size
+	}
+	if len(langs) > 0 {
+		r.LanguageBreakdown = langs
+		r.NonCodeRatio = nonCodeRatio(r.SizeKB, langs)
+	}
+	r.prefetched = map[string]bool{"languages": true}
+
+	if d.DefaultBranchRef != nil && d.DefaultBranchRef.Target.OID != "" {
+		head := d.DefaultBranchRef.Target
+		r.LastCommitAt = localizeTimestamp(head.AuthoredDate)
+		r.LastCommitMessage = shortCommitMessage(head.Message)
+		r.HeadSHA = head.OID
+		r.prefetched["commits"] = true
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDoGraphQLFieldErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		wantField map[string]string // nil when the whole query should fail
+	}{
+		{
+			"one repo missing",
+			`{"data":{"r0":{"nameWithOwner":"o/a"},"r1":null},"errors":[{"message":"Could not resolve to a Repository","path":["r1"]}]}`,
+			map[string]string{"r1": "Could not resolve to a Repository"},
+		},
+		{
+			"nested path",
+			`{"data":{"r0":{"nameWithOwner":"o/a"},"r1":null},"errors":[{"message":"boom","path":["r1","languages",0]}]}`,
+			map[string]string{"r1": "boom"},
+		},
+		{"no path", `{"data":{"r0":null},"errors":[{"message":"rate limited"}]}`, nil},
+		{"no data", `{"data":null,"errors":[{"message":"bad query","path":["r0"]}]}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tt.response)
+			}))
+
+			var data map[string]*struct {
+				NameWithOwner string `json:"nameWithOwner"`
+			}
+			err := doGraphQL(context.Background(), http.DefaultClient, "token", "query { x }", nil, &data)
+			var fieldErrs graphqlFieldErrors
+			if tt.wantField == nil {
+				if err == nil || errors.As(err, &fieldErrs) {
+					t.Fatalf("err = %v, want the whole query to fail", err)
+				}
+				return
+			}
+			if !errors.As(err, &fieldErrs) {
+				t.Fatalf("err = %v, want field errors", err)
+			}
+			if fmt.Sprint(map[string]string(fieldErrs)) != fmt.Sprint(tt.wantField) {
+				t.Errorf("field errors %v, want %v", fieldErrs, tt.wantField)
+			}
+			if data["r0"] == nil || data["r0"].NameWithOwner != "o/a" {
+				t.Errorf("r0 = %+v, want the field that succeeded", data["r0"])
+			}
+		})
+	}
+}
+
+func TestFetchGraphQLRepoDataSkipsFailedRepo(t *testing.T) {
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{
+			"r0":{"nameWithOwner":"o/a","languages":{"edges":[{"size":10,"node":{"name":"Go"}}]},"defaultBranchRef":null},
+			"r1":null},
+			"errors":[{"type":"NOT_FOUND","message":"Could not resolve to a Repository with the name 'o/gone'.","path":["r1"]}]}`)
+	}))
+
+	got := fetchGraphQLRepoData(context.Background(), http.DefaultClient, "token", []string{"o/a", "o/gone"})
+	if _, ok := got["o/gone"]; ok || len(got) != 1 {
+		t.Fatalf("got %v, want just o/a", got)
+	}
+	if edges := got["o/a"].Languages.Edges; len(edges) != 1 || edges[0].Node.Name != "Go" {
+		t.Errorf("o/a languages %+v", edges)
+	}
+}
+
+func TestFetchGraphQLEngagementKeepsGoodBatches(t *testing.T) {
+	calls := 0
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 2 {
+			http.Error(w, "boom", http.StatusBadGateway)
+			return
+		}
+		// Each repo of the batch answers under its own name
+		var req struct {
+			Variables map[string]string `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		data := map[string]interface{}{}
+		for i := 0; i < len(req.Variables)/2; i++ {
+			data[fmt.Sprintf("r%d", i)] = map[string]interface{}{
+				"nameWithOwner":  req.Variables[fmt.Sprintf("o%d", i)] + "/" + req.Variables[fmt.Sprintf("n%d", i)],
+				"stargazerCount": 5,
+				"watchers":       map[string]int{"totalCount": 2},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+
+	names := make([]string, 3*graphqlBatchSize)
+	for i := range names {
+		names[i] = fmt.Sprintf("o/r%d", i)
+	}
+	counts, err := fetchGraphQLEngagement(context.Background(), http.DefaultClient, "token", names)
+	if err == nil {
+		t.Error("want the failed batch reported")
+	}
+	if len(counts) != 2*graphqlBatchSize {
+		t.Fatalf("%d counts, want the %d of the two good batches", len(counts), 2*graphqlBatchSize)
+	}
+	for i, full := range names {
+		_, ok := counts[full]
+		if failed := i >= graphqlBatchSize && i < 2*graphqlBatchSize; ok == failed {
+			t.Errorf("%s: has counts %v, in the failed batch %v", full, ok, failed)
+		}
+	}
+}
+
+func TestFetchGraphQLEngagementKeysByRequestedName(t *testing.T) {
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"r0":{"nameWithOwner":"o/new-name","stargazerCount":5,"watchers":{"totalCount":2}}}}`)
+	}))
+
+	counts, err := fetchGraphQLEngagement(context.Background(), http.DefaultClient, "token", []string{"o/old-name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := counts["o/old-name"]; !ok || c.Stars != 5 || len(counts) != 1 {
+		t.Errorf("counts %v, want the renamed repo under o/old-name", counts)
+	}
+}
+
+func TestDoGraphQLRetriesRateLimits(t *testing.T) {
+	oldRetries, oldWait := maxRetries, maxRetryWait
+	maxRetries, maxRetryWait = 2, time.Millisecond
+	t.Cleanup(func() { maxRetries, maxRetryWait = oldRetries, oldWait })
+
+	reset := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	tests := []struct {
+		name  string
+		first func(w http.ResponseWriter)
+	}{
+		{"secondary limit", func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "You have exceeded a secondary rate limit", http.StatusForbidden)
+		}},
+		{"too many requests", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}},
+		{"primary limit", func(w http.ResponseWriter) {
+			w.Header().Set("X-RateLimit-Resource", "graphql")
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", reset)
+			fmt.Fprint(w, `{"errors":[{"type":"RATE_LIMITED","message":"API rate limit exceeded"}]}`)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					tt.first(w)
+					return
+				}
+				fmt.Fprint(w, `{"data":{"viewer":{"login":"me"}}}`)
+			}))
+
+			var data struct {
+				Viewer struct {
+					Login string `json:"login"`
+				} `json:"viewer"`
+			}
+			if err := doGraphQL(context.Background(), http.DefaultClient, "token", "query { viewer { login } }", nil, &data); err != nil {
+				t.Fatal(err)
+			}
+			if calls != 2 || data.Viewer.Login != "me" {
+				t.Errorf("%d calls, login %q; want the retry to succeed", calls, data.Viewer.Login)
+			}
+		})
+	}
+}
+
+func TestGraphQLAvailableProbesOnlyWhenNeeded(t *testing.T) {
+	tests := []struct {
+		name       string
+		calendar   bool
+		args       []string
+		wantProbes int
+	}{
+		{"nothing uses it", false, nil, 0},
+		{"calendar", true, nil, 1},
+		{"engagement", false, []string{"--graphql-engagement"}, 1},
+		{"api", false, []string{"--api=graphql"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probes := 0
+			newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				probes++
+				fmt.Fprint(w, `{"data":{"viewer":{"login":"me"}}}`)
+			}))
+			opts, err := parseOptions(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ok := graphqlAvailable(context.Background(), http.DefaultClient, "token", opts, tt.calendar)
+			if probes != tt.wantProbes || ok != (tt.wantProbes > 0) {
+				t.Errorf("%d probes, available %v; want %d probes", probes, ok, tt.wantProbes)
+			}
+		})
+	}
+}
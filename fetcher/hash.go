@@ -0,0 +1,28 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// contentHash is a sha256 over a repo's enrichment fields. encoding/json
+// writes struct fields in declaration order and map keys sorted, so equal
+// data always hashes the same and a later run can tell unchanged repos by
+// comparing hashes.
+func contentHash(e repoEnrichment) string {
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// applyContentHashes sets ContentHash on every repo with all (--hash), and
+// otherwise refreshes only the repos that already carry one, so a partial
+// update of an existing index doesn't leave stale hashes behind.
+func applyContentHashes(out []outRepo, all bool) {
+	for i := range out {
+		if all || out[i].ContentHash != "" {
+			out[i].ContentHash = contentHash(out[i].repoEnrichment)
+		}
+	}
+}
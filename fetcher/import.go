@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// fetchImportStatus returns the status of a source import into the repo
+// (the GitHub Importer). found is false when the repo was never imported.
+func fetchImportStatus(ctx context.Context, client *http.Client, token, fullName string) (string, bool, error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/import", fullName))
+	status, body, err := doGET(ctx, client, url, token)
+	if err != nil {
+		return "", false, err
+	}
+	if status == 404 {
+		return "", false, nil
+	}
+	if status < 200 || status >= 300 {
+		return "", false, fmt.Errorf("import status error %d", status)
+	}
+
+	var imp struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &imp); err != nil {
+		return "", false, err
+	}
+	return imp.Status, true, nil
+}
+
+// importInProgress reports whether an import status means history is
+// still arriving, so the repo's commits and stats aren't meaningful yet.
+func importInProgress(status string) bool {
+	switch status {
+	case "", "none", "complete", "error", "auth_failed", "detection_needs_auth",
+		"detection_found_nothing", "detection_found_multiple":
+		return false
+	}
+	return true
+}
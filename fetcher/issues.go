@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// highOpenIssueRatio is the open/total share at or above which a repo is
+// flagged as accumulating issues faster than it resolves them.
+const highOpenIssueRatio = 0.5
+
+// intervalLimiter spaces calls at least every apart across goroutines.
+type intervalLimiter struct {
+	mu    sync.Mutex
+	next  time.Time
+	every time.Duration
+}
+
+func (l *intervalLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.every)
+	l.mu.Unlock()
+
+	return sleepCtx(ctx, delay)
+}
+
+// searchLimiter keeps search API calls under their separate quota of 30
+// requests per minute.
+var searchLimiter = &intervalLimiter{every: 2 * time.Second}
+
+func searchIssueCount(ctx context.Context, client *http.Client, token, query string) (int, error) {
+	if err := searchLimiter.wait(ctx); err != nil {
+		return 0, err
+	}
+
+	u := apiURL("/search/issues?per_page=1&q=" + url.QueryEscape(query))
+	status, body, err := doGET(ctx, client, u, token)
+	if err != nil {
+		return 0, err
+	}
+	if status < 200 || status >= 300 {
+		return 0, fmt.Errorf("issue search error %d", status)
+	}
+
+	var res struct {
+		TotalCount int `json:"total_count"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return 0, err
+	}
+	return res.TotalCount, nil
+}
+
+// fetchIssueCounts returns the numbers of open and closed issues (pull
+// requests excluded) via the search API.
+func fetchIssueCounts(ctx context.Context, client *http.Client, token, fullName string) (int, int, error) {
+	open, err := searchIssueCount(ctx, client, token, fmt.Sprintf("repo:%s type:issue state:open", fullName))
+	if err != nil {
+		return 0, 0, err
+	}
+	closed, err := searchIssueCount(ctx, client, token, fmt.Sprintf("repo:%s type:issue state:closed", fullName))
+	if err != nil {
+		return 0, 0, err
+	}
+	return open, closed, nil
+}
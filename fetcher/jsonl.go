@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// indexStream writes the index as JSON lines (--format=jsonl), one repo per
+// line as soon as its enrichment is done, so the full array is never built
+// in memory and readers can follow the file during the run. --where,
+// --fields and --hash apply per line as they would to the whole index.
+type indexStream struct {
+	mu     sync.Mutex
+	f      *os.File
+	enc    *json.Encoder
+	where  whereExpr
+	fields []string
+	hash   bool
+	err    error // first write error; later writes are dropped
+}
+
+func newIndexStream(path string, opts options, where whereExpr) (*indexStream, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &indexStream{f: f, enc: json.NewEncoder(f), where: where, fields: opts.fieldList, hash: opts.Hash}, nil
+}
+
+// write appends r; it is safe to call from several workers.
+func (s *indexStream) write(r *outRepo) {
+	if s.hash {
+		r.ContentHash = contentHash(r.repoEnrichment)
+	}
+
+	if s.where != nil {
+		kept, err := filterWhere([]outRepo{*r}, s.where)
+		if err != nil || len(kept) == 0 {
+			s.fail(err)
+			return
+		}
+	}
+	var rec interface{} = r
+	if s.fields != nil {
+		projected, err := projectFields([]outRepo{*r}, s.fields)
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		rec = projected[0]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = s.enc.Encode(rec)
+	}
+}
+
+func (s *indexStream) fail(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Close flushes the file and returns the first error of the stream.
+func (s *indexStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.f.Close(); s.err == nil {
+		s.err = err
+	}
+	return s.err
+}
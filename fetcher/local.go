@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// localSizeKB measures the working tree of a checkout, leaving out .git so
+// it compares with what a clone puts on disk outside the object store.
+// Unreadable entries are skipped rather than failing the measurement.
+func localSizeKB(dir string) int {
+	var total int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return int((total + 1023) / 1024)
+}
+
+// applyLocalSizes sets LocalSizeKB on repos checked out under
+// root/owner/repo. Repos without a local directory are left alone.
+func applyLocalSizes(out []outRepo, root string) {
+	if _, err := os.Stat(root); err != nil {
+		logWarnf("⚠️  --local-root: %v", err)
+		return
+	}
+
+	found := 0
+	for i := range out {
+		dir := filepath.Join(root, filepath.FromSlash(out[i].FullName))
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		out[i].LocalSizeKB = localSizeKB(dir)
+		found++
+	}
+	logInfof("💽 Measured %d local checkouts under %s", found, root)
+}
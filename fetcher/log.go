@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// logger receives the progress output. The default human format prints
+// each message as is, emoji and blank lines included; --log-format=text or
+// json emits slog records instead, for CI logs. Attributes only show up in
+// the structured formats: human messages already spell them out.
+var logger = slog.New(newHumanHandler(os.Stdout, slog.LevelInfo))
+
+func logDebugf(format string, args ...interface{}) { logger.Debug(fmt.Sprintf(format, args...)) }
+func logInfof(format string, args ...interface{})  { logger.Info(fmt.Sprintf(format, args...)) }
+func logWarnf(format string, args ...interface{})  { logger.Warn(fmt.Sprintf(format, args...)) }
+
+// newLogger builds the logger for --log-format and --log-level, writing to w.
+func newLogger(w io.Writer, format string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "text":
+		return slog.New(plainHandler{slog.NewTextHandler(w, opts)})
+	case "json":
+		return slog.New(plainHandler{slog.NewJSONHandler(w, opts)})
+	}
+	return slog.New(newHumanHandler(w, level))
+}
+
+// humanHandler writes just the message of each record, one per line.
+type humanHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Level
+}
+
+func newHumanHandler(w io.Writer, level slog.Level) *humanHandler {
+	return &humanHandler{mu: &sync.Mutex{}, w: w, level: level}
+}
+
+func (h *humanHandler) Enabled(_ context.Context, l slog.Level) bool { return l >= h.level }
+
+func (h *humanHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintln(h.w, r.Message)
+	return err
+}
+
+func (h *humanHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *humanHandler) WithGroup(string) slog.Handler      { return h }
+
+// plainHandler strips the decoration meant for humans (leading emoji,
+// indentation, blank lines) before passing records on.
+type plainHandler struct{ slog.Handler }
+
+func (h plainHandler) Handle(ctx context.Context, r slog.Record) error {
+	msg := strings.TrimLeftFunc(r.Message, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '-' && c != '('
+	})
+	msg = strings.TrimSpace(msg)
+	if msg == "" {
+		return nil
+	}
+	plain := slog.NewRecord(r.Time, r.Level, msg, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		plain.AddAttrs(a)
+		return true
+	})
+	return h.Handler.Handle(ctx, plain)
+}
+
+func (h plainHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return plainHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h plainHandler) WithGroup(name string) slog.Handler {
+	return plainHandler{h.Handler.WithGroup(name)}
+}
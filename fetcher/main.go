@@ -1,17 +1,29 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"math"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
-
-	"github.com/joho/godotenv"
+	"unicode/utf8"
 )
 
 type ghRepo struct {
@@ -67,6 +79,12 @@ type weeklyStat struct {
 	Days  []int `json:"days"`
 }
 
+type weeklyCodeFrequency struct {
+	Week      int64 `json:"w"`
+	Additions int   `json:"additions"`
+	Deletions int   `json:"deletions"`
+}
+
 type languageStats map[string]int
 
 type contributor struct {
@@ -74,6 +92,10 @@ type contributor struct {
 	Contributions int    `json:"contributions"`
 }
 
+// outRepo is one record of repos_index_enriched.json. Its keys are a
+// superset of the basic index (repos_index.json), so a consumer of that
+// can switch to this file unchanged. Run with -schema-diff=<file> to check
+// another index against this shape.
 type outRepo struct {
 	Name          string   `json:"name"`
 	FullName      string   `json:"full_name"`
@@ -91,16 +113,23 @@ type outRepo struct {
 	SizeKB       int    `json:"size_kb"`
 	SizeReadable string `json:"size_readable"`
 
-	// Engagement
+	// Engagement. From REST, watchers is the same number as stars (GitHub
+	// kept watchers_count as a legacy alias of stargazers_count);
+	// --graphql-engagement replaces it with the real watcher count.
 	Stars      int `json:"stars"`
 	Forks      int `json:"forks"`
 	Watchers   int `json:"watchers"`
 	OpenIssues int `json:"open_issues"`
+	// Stars and Forks again under the basic index's names
+	StargazersCount int `json:"stargazers_count"`
+	ForksCount      int `json:"forks_count"`
 
 	// Timestamps
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
 	PushedAt  string `json:"pushed_at"`
+	// 1 = most recently pushed repo of the account
+	FreshnessRank int `json:"freshness_rank"`
 
 	// URLs
 	HTMLURL string `json:"html_url"`
@@ -108,6 +137,7 @@ type outRepo struct {
 	// Owner
 	OwnerLogin string `json:"owner_login"`
 	OwnerType  string `json:"owner_type"`
+	MyRole     string `json:"my_role"`
 
 	// License
 	License string `json:"license"`
@@ -119,20 +149,169 @@ type outRepo struct {
 	HasPages     bool `json:"has_pages"`
 	HasDownloads bool `json:"has_downloads"`
 
+	// User-supplied notes (--annotations)
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Time spent enriching this repo (--timings)
+	EnrichDurationMs int `json:"enrich_duration_ms,omitempty"`
+
+	// Working-tree size of a local checkout (--local-root)
+	LocalSizeKB int `json:"local_size_kb,omitempty"`
+
+	// sha256 of the enrichment fields (--hash)
+	ContentHash string `json:"content_hash,omitempty"`
+
 	// Enrichment data
+	repoEnrichment
+}
+
+// repoEnrichment holds the fields filled in by the per-repo API calls. It is
+// kept apart from the listing metadata so it can be carried over from a
+// previous run as a unit.
+type repoEnrichment struct {
 	LastCommitAt      string         `json:"last_commit_at"`
 	LastCommitMessage string         `json:"last_commit_message"`
+	HeadSHA           string         `json:"head_sha"`
 	WeeklyCommits52W  []int          `json:"weekly_commits_52w"`
 	WeeklyStats52W    []weeklyStat   `json:"weekly_stats_52w"`
 	LanguageBreakdown map[string]int `json:"language_breakdown"`
 	TopContributors   []contributor  `json:"top_contributors"`
 	ContributorCount  int            `json:"contributor_count"`
 	TotalCommits      int            `json:"total_commits"`
-	StatsCachePending bool           `json:"stats_cache_pending"`
+	// Share of the repo size not attributed to any language
+	NonCodeRatio float64 `json:"non_code_ratio,omitempty"`
+	// Rough cadence over the 52-week window; 0 when there were no commits
+	AvgDaysBetweenCommits float64 `json:"avg_days_between_commits,omitempty"`
+	StatsCachePending     bool    `json:"stats_cache_pending"`
+	StatsSkipped          bool    `json:"stats_skipped,omitempty"`
+	RepoDeleted           bool    `json:"repo_deleted,omitempty"`
+	ImportPending         bool    `json:"import_pending,omitempty"`
+	IsEmpty               bool    `json:"is_empty,omitempty"`
+
+	// Top contributor's share of the listed contributors' commits; 1 when
+	// one person made them all
+	ContributionConcentration float64 `json:"contribution_concentration,omitempty"`
+
+	// Weekly additions and deletions over 52 weeks (--code-frequency)
+	WeeklyCodeFrequency []weeklyCodeFrequency `json:"weekly_code_frequency,omitempty"`
+
+	// Open pull requests, and open_issues without them (--open-prs);
+	// GitHub's open_issues count includes pull requests
+	OpenPRs        int `json:"open_prs,omitempty"`
+	OpenIssuesOnly int `json:"open_issues_only,omitempty"`
+
+	// Weekly commits over 52 weeks by everyone and by the owner
+	// (--participation)
+	ParticipationAll   []int `json:"participation_all,omitempty"`
+	ParticipationOwner []int `json:"participation_owner,omitempty"`
+
+	// All-time commits, summed over /stats/contributors (--lifetime-commits);
+	// total_commits only covers 52 weeks
+	LifetimeCommits int `json:"lifetime_commits,omitempty"`
+
+	// Latest published release (--releases)
+	LatestReleaseTag  string `json:"latest_release_tag,omitempty"`
+	LatestReleaseName string `json:"latest_release_name,omitempty"`
+	LatestReleaseAt   string `json:"latest_release_at,omitempty"`
+
+	// Newest branch tip commit (--all-branches); at least last_commit_at
+	LastCommitAtAnyBranch string `json:"last_commit_at_any_branch,omitempty"`
+
+	// Community profile
+	HasCommunityProfile       bool `json:"has_community_profile"`
+	CommunityHealthPercentage int  `json:"community_health_percentage"`
+	HasCodeOfConduct          bool `json:"has_code_of_conduct"`
+	HasContributing           bool `json:"has_contributing"`
+
+	// Merge settings (--merge-settings); known only with push access
+	MergeSettingsKnown  bool `json:"merge_settings_known,omitempty"`
+	AllowSquashMerge    bool `json:"allow_squash_merge,omitempty"`
+	AllowMergeCommit    bool `json:"allow_merge_commit,omitempty"`
+	AllowRebaseMerge    bool `json:"allow_rebase_merge,omitempty"`
+	DeleteBranchOnMerge bool `json:"delete_branch_on_merge,omitempty"`
+
+	// Branch count and default branch protection (--branches); protection
+	// is known only with admin access
+	BranchCount                  int  `json:"branch_count,omitempty"`
+	DefaultBranchProtectionKnown bool `json:"default_branch_protection_known,omitempty"`
+	DefaultBranchProtected       bool `json:"default_branch_protected,omitempty"`
+
+	// Fork vs upstream (--fork-compare)
+	ForkParent   string `json:"fork_parent,omitempty"`
+	ForkBehindBy int    `json:"fork_behind_by,omitempty"`
+	ForkAheadBy  int    `json:"fork_ahead_by,omitempty"`
+
+	// Issue resolution (--issue-ratio)
+	IssuesTotal        int     `json:"issues_total,omitempty"`
+	IssueCloseRatio    float64 `json:"issue_close_ratio,omitempty"`
+	HighOpenIssueRatio bool    `json:"high_open_issue_ratio,omitempty"`
+
+	// Open pull request authors (--pr-authors); external ones aren't
+	// owners, members or collaborators of the repo
+	OpenPRAuthors     []string `json:"open_pr_authors,omitempty"`
+	ExternalPRAuthors []string `json:"external_pr_authors,omitempty"`
+
+	// Default GITHUB_TOKEN permissions, read or write (--workflow-permissions);
+	// needs admin access
+	DefaultWorkflowPermissions string `json:"default_workflow_permissions,omitempty"`
+
+	// Actions workflow runs and the outcome of the latest (--workflow-runs);
+	// no_actions when Actions is disabled
+	WorkflowRunCount         int    `json:"workflow_run_count,omitempty"`
+	LatestWorkflowConclusion string `json:"latest_workflow_conclusion,omitempty"`
+
+	// CI providers with config in the repo (--ci-detect)
+	CIProviders []string `json:"ci_providers,omitempty"`
+
+	// Error per enrichment step that failed; the step's fields are empty
+	EnrichmentErrors map[string]string `json:"enrichment_errors,omitempty"`
+
+	// Steps that ran to completion, so a later run can tell whether this
+	// record covers its own steps before carrying it over (see
+	// reusableArchived); never part of a --fields projection
+	EnrichedSteps []string `json:"enriched_steps,omitempty"`
+
+	// Steps already filled in from GraphQL (--api=graphql); not output
+	prefetched map[string]bool
+
+	// Stats steps GitHub was still computing (202); not output, but
+	// listed in the pending file for --resume-pending
+	statsPending map[string]bool
+}
+
+// langStat is one language of the summary's combined language view.
+type langStat struct {
+	Language string  `json:"language"`
+	Repos    int     `json:"repos"`
+	Bytes    int64   `json:"bytes"`
+	Percent  float64 `json:"percent"`
+}
+
+type nonCodeRepo struct {
+	FullName     string  `json:"full_name"`
+	SizeKB       int     `json:"size_kb"`
+	NonCodeRatio float64 `json:"non_code_ratio"`
+}
+
+type repoTiming struct {
+	FullName   string `json:"full_name"`
+	DurationMs int    `json:"duration_ms"`
 }
 
 type summary struct {
 	GeneratedAt string `json:"generated_at"`
+	ToolVersion string `json:"tool_version"`
+
+	Owner struct {
+		Login              string `json:"login"`
+		Name               string `json:"name"`
+		Followers          int    `json:"followers"`
+		Following          int    `json:"following"`
+		PublicRepos        int    `json:"public_repos"`
+		TotalContributions int    `json:"total_contributions"`
+		CurrentStreakDays  int    `json:"current_streak_days"`
+		LongestStreakDays  int    `json:"longest_streak_days"`
+	} `json:"owner"`
 
 	RepoCounts struct {
 		Total    int `json:"total"`
@@ -142,6 +321,13 @@ type summary struct {
 		Forks    int `json:"forks"`
 		Org      int `json:"org_owned_or_member"`
 		User     int `json:"user_owned"`
+		// By my_role, so a collaborator repo owned by another user isn't
+		// counted as mine the way user_owned counts it
+		OwnedByMe         int `json:"owned_by_me"`
+		OrgRepos          int `json:"org_repos"`
+		CollaboratorRepos int `json:"collaborator_repos"`
+		// With at least one published release (--releases)
+		WithReleases int `json:"with_releases"`
 	} `json:"repo_counts"`
 
 	Size struct {
@@ -154,12 +340,56 @@ type summary struct {
 		TotalForks    int `json:"total_forks"`
 		TotalWatchers int `json:"total_watchers"`
 		TotalCommits  int `json:"total_commits"`
+		// Open pull requests and the open issues without them (--open-prs)
+		TotalOpenPRs        int `json:"total_open_prs"`
+		TotalOpenIssuesOnly int `json:"total_open_issues_only"`
+		// Share of the 52-week commits not made by the repo owners
+		// (--participation)
+		CommunityCommitRatio float64 `json:"community_commit_ratio"`
+		// Mean contribution_concentration of the repos with contributors
+		AvgContributionConcentration float64 `json:"avg_contribution_concentration"`
+		// Lines added plus deleted over 52 weeks (--code-frequency)
+		TotalChurn52W int `json:"total_churn_52w"`
+		// All-time commits (--lifetime-commits), next to the 52-week
+		// total_commits
+		TotalLifetimeCommits int `json:"total_lifetime_commits"`
 	} `json:"engagement"`
 
+	StarBuckets map[string]int `json:"star_buckets"`
+
+	// The same star buckets and open issue buckets (--open-issue-buckets)
+	// as arrays, for sparklines
+	Distributions struct {
+		Stars      distribution `json:"stars"`
+		OpenIssues distribution `json:"open_issues"`
+	} `json:"distributions"`
+
 	Languages map[string]int `json:"languages"`
 	Topics    map[string]int `json:"topics"`
 	Licenses  map[string]int `json:"licenses"`
 
+	LanguageSummary []langStat `json:"language_summary"`
+
+	// Bytes per language summed over every repo's breakdown, and each
+	// language's share of them; languages only counts primary languages
+	LanguageBytes   map[string]int64   `json:"language_bytes"`
+	LanguagePercent map[string]float64 `json:"language_percent"`
+
+	ContributorLeaderboard []contributor `json:"contributor_leaderboard"`
+
+	BinaryHeavyRepos []nonCodeRepo `json:"binary_heavy_repos"`
+
+	ByOwner map[string]ownerStats `json:"by_owner"`
+
+	// Descriptions shared by more than one repo, often accidental clones
+	DuplicateDescriptions map[string][]string `json:"duplicate_descriptions"`
+
+	// Filled with --ci-detect; a repo with several providers counts for each
+	CIProviders map[string]int `json:"ci_providers,omitempty"`
+
+	// Only filled with --timings
+	SlowestRepos []repoTiming `json:"slowest_repos,omitempty"`
+
 	Activity struct {
 		MostRecentUpdate string `json:"most_recent_update"`
 		MostRecentPush   string `json:"most_recent_push"`
@@ -173,154 +403,734 @@ type summary struct {
 		ReposWithLanguages    int `json:"repos_with_languages"`
 		ReposWithContributors int `json:"repos_with_contributors"`
 		ReposStatsPending     int `json:"repos_stats_pending"`
+		ReposDeleted          int `json:"repos_deleted"`
+		ReposImportPending    int `json:"repos_import_pending"`
+		ReposEmpty            int `json:"repos_empty"`
+		ReposWithErrors       int `json:"repos_with_errors"`
 	} `json:"enrichment"`
+
+	Cadence struct {
+		ReposWithCommits     int     `json:"repos_with_commits"`
+		MinAvgDaysBetween    float64 `json:"min_avg_days_between_commits"`
+		MaxAvgDaysBetween    float64 `json:"max_avg_days_between_commits"`
+		MedianAvgDaysBetween float64 `json:"median_avg_days_between_commits"`
+	} `json:"cadence"`
+
+	Community struct {
+		ReposWithProfile       int     `json:"repos_with_profile"`
+		AvgHealthPercentage    float64 `json:"avg_health_percentage"`
+		ReposWithCodeOfConduct int     `json:"repos_with_code_of_conduct"`
+		ReposWithContributing  int     `json:"repos_with_contributing"`
+	} `json:"community"`
+
+	// Filled with --workflow-permissions; a write default is a common audit
+	// finding
+	WorkflowPermissions struct {
+		ReposChecked      int      `json:"repos_checked"`
+		ReposDefaultWrite int      `json:"repos_default_write"`
+		DefaultWrite      []string `json:"default_write,omitempty"`
+	} `json:"workflow_permissions"`
+
+	MergePolicy struct {
+		ReposChecked   int                 `json:"repos_checked"`
+		ReposDeviating int                 `json:"repos_deviating"`
+		Deviations     map[string][]string `json:"deviations,omitempty"`
+	} `json:"merge_policy"`
+
+	Issues struct {
+		ReposWithIssueData int     `json:"repos_with_issue_data"`
+		AvgIssueCloseRatio float64 `json:"avg_issue_close_ratio"`
+		ReposHighOpenRatio int     `json:"repos_high_open_ratio"`
+	} `json:"issues"`
+
+	// Filled with --pr-authors; authors internal to any repo count as
+	// internal
+	PullRequests struct {
+		ReposWithOpenPRs int `json:"repos_with_open_prs"`
+		InternalAuthors  int `json:"internal_authors"`
+		ExternalAuthors  int `json:"external_authors"`
+	} `json:"pull_requests"`
+}
+
+// logOut is where the logger writes the progress output.
+var logOut io.Writer = os.Stdout
+
+// defaultAPIVersion is the REST API version requested unless --api-version
+// says otherwise. Pinning it keeps responses stable when GitHub ships
+// breaking changes to unversioned clients.
+const defaultAPIVersion = "2022-11-28"
+
+// apiVersion is sent as X-GitHub-Api-Version on every REST request.
+var apiVersion = defaultAPIVersion
+
+// defaultBaseURL is the public REST API; Enterprise Server lives at
+// https://HOST/api/v3.
+const defaultBaseURL = "https://api.github.com"
+
+// baseURL is the REST API root every endpoint is built on (--base-url).
+var baseURL = defaultBaseURL
+
+// apiURL builds an endpoint URL from its path, e.g. "/repos/me/tool".
+func apiURL(path string) string {
+	return baseURL + path
+}
+
+// graphqlURL is the GraphQL endpoint next to baseURL: /graphql on the
+// public API, /api/graphql beside /api/v3 on Enterprise Server.
+func graphqlURL() string {
+	if baseURL == defaultBaseURL {
+		return defaultBaseURL + "/graphql"
+	}
+	return strings.TrimSuffix(baseURL, "/v3") + "/graphql"
 }
 
-func mustToken() string {
-	token := strings.TrimSpace(os.Getenv("GITHUB_TOKEN"))
-	if token == "" {
-		panic("GITHUB_TOKEN is missing. Put it in .env as: GITHUB_TOKEN=ghp_... (no quotes) or export it in your shell.")
+// defaultOutputDir is the repo root when run from fetcher/, where the
+// renderer expects the files.
+const defaultOutputDir = ".."
+
+const (
+	indexFile      = "repos_index_enriched.json"
+	indexCSVFile   = "repos_index_enriched.csv"
+	indexJSONLFile = "repos_index_enriched.jsonl"
+	summaryFile    = "repos_summary.json"
+	reportFile     = "repos_report.md"
+	metricsFile    = "repos_metrics.prom"
+	changesFile    = "repos_changes.json"
+	errorsFile     = "repos_errors.jsonl"
+	pendingFile    = "repos_stats_pending.jsonl"
+)
+
+// Where the output files are read and written; see setOutputDir.
+var (
+	indexPath      = filepath.Join(defaultOutputDir, indexFile)
+	indexCSVPath   = filepath.Join(defaultOutputDir, indexCSVFile)
+	indexJSONLPath = filepath.Join(defaultOutputDir, indexJSONLFile)
+	summaryPath    = filepath.Join(defaultOutputDir, summaryFile)
+	reportPath     = filepath.Join(defaultOutputDir, reportFile)
+	metricsPath    = filepath.Join(defaultOutputDir, metricsFile)
+	changesPath    = filepath.Join(defaultOutputDir, changesFile)
+	errorsPath     = filepath.Join(defaultOutputDir, errorsFile)
+	pendingPath    = filepath.Join(defaultOutputDir, pendingFile)
+)
+
+// setOutputDir points the output files at dir, creating it if needed.
+func setOutputDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
 	}
-	return token
+	indexPath = filepath.Join(dir, indexFile)
+	indexCSVPath = filepath.Join(dir, indexCSVFile)
+	indexJSONLPath = filepath.Join(dir, indexJSONLFile)
+	summaryPath = filepath.Join(dir, summaryFile)
+	reportPath = filepath.Join(dir, reportFile)
+	metricsPath = filepath.Join(dir, metricsFile)
+	changesPath = filepath.Join(dir, changesFile)
+	errorsPath = filepath.Join(dir, errorsFile)
+	pendingPath = filepath.Join(dir, pendingFile)
+	return nil
+}
+
+// fatalWrite reports a failed output write and exits; a run whose results
+// can't be saved shouldn't look like it succeeded.
+func fatalWrite(what string, err error) {
+	fmt.Fprintf(os.Stderr, "❌ Could not write %s: %v\n", what, err)
+	os.Exit(1)
 }
 
+// sizeUnits are the units of humanSizeFromKB and parseHumanSize, each 1024
+// times the one before.
+var sizeUnits = []string{"B", "KB", "MB", "GB", "TB"}
+
+// humanSizeFromKB renders a size in KB in the largest unit it reaches:
+// whole KB below 1 MB, one decimal from MB up. The unit is picked by
+// comparison rather than logarithms, so exact powers of 1024 (1024 KB is
+// "1.0 MB") and values that round up to the next unit (1048575 KB is
+// "1.0 GB", not "1024.0 MB") land in the right one.
 func humanSizeFromKB(kb int) string {
-	bytes := float64(kb) * 1024
-	if bytes <= 0 {
+	if kb <= 0 {
 		return "0 B"
 	}
-	units := []string{"B", "KB", "MB", "GB", "TB"}
-	i := int(math.Floor(math.Log(bytes) / math.Log(1024)))
+	val, i := float64(kb), 1
+	for i < len(sizeUnits)-1 && val >= 1024 {
+		val /= 1024
+		i++
+	}
+	if i == 1 {
+		return fmt.Sprintf("%d KB", kb)
+	}
+	if math.Round(val*10) >= 10240 && i < len(sizeUnits)-1 {
+		val /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", val, sizeUnits[i])
+}
+
+// humanSizeRe matches a size like "1.5 GB", "512kb" or "100": a number and
+// an optional unit, KB when omitted.
+var humanSizeRe = regexp.MustCompile(`^(\d+(?:\.\d+)?|\.\d+)\s*([a-zA-Z]*)$`)
+
+// parseHumanSize is the inverse of humanSizeFromKB: it reads a size in any
+// of its units (case insensitive, "K"/"M"/"G"/"T" also accepted) and
+// returns it in KB, rounded to the nearest.
+func parseHumanSize(s string) (int, error) {
+	m := humanSizeRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q, want e.g. 512 KB or 1.5 GB", s)
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	unit := strings.ToUpper(m[2])
+	if unit == "" {
+		unit = "KB"
+	} else if len(unit) == 1 && unit != "B" {
+		unit += "B"
+	}
+	i := slices.Index(sizeUnits, unit)
 	if i < 0 {
-		i = 0
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q, want B, KB, MB, GB or TB", s, m[2])
 	}
-	if i >= len(units) {
-		i = len(units) - 1
+	kb := n * math.Pow(1024, float64(i-1))
+	if kb >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", s)
 	}
-	val := bytes / math.Pow(1024, float64(i))
-	if units[i] == "B" || units[i] == "KB" {
-		return fmt.Sprintf("%.0f %s", val, units[i])
+	return int(math.Round(kb)), nil
+}
+
+// checkOutputSize warns when the index is bigger than warnMB and refuses it
+// when it's bigger than hardMB. A limit of 0 disables that check.
+func checkOutputSize(size int, warnMB, hardMB float64) error {
+	mb := float64(size) / (1024 * 1024)
+	if hardMB > 0 && mb > hardMB {
+		return fmt.Errorf("index is %.1f MB, over the --max-output-hard-mb limit of %.1f MB; nothing was written. "+
+			"Narrow the run with --where, or raise the limit", mb, hardMB)
 	}
-	return fmt.Sprintf("%.1f %s", val, units[i])
+	if warnMB > 0 && mb > warnMB {
+		logWarnf("⚠️  Index is %.1f MB (over %.1f MB); downstream dashboards may struggle. "+
+			"Consider narrowing it with --where or compressing it.", mb, warnMB)
+	}
+	return nil
+}
+
+// median returns the median of an already sorted slice.
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return math.Round((sorted[n/2-1]+sorted[n/2])/2*100) / 100
 }
 
-func doGET(client *http.Client, url string, token string) (int, []byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func doGET(ctx context.Context, client *http.Client, url string, token string) (int, []byte, error) {
+	status, _, body, err := doGETWithHeaders(ctx, client, url, token)
+	return status, body, err
+}
+
+// doGETWithHeaders is doGET for callers that also need the response
+// headers, e.g. to read pagination from the Link header.
+func doGETWithHeaders(ctx context.Context, client *http.Client, url string, token string) (int, http.Header, []byte, error) {
+	return sendWithRetries(ctx, url, resourceFor(url), func() (int, http.Header, []byte, error) {
+		return getOnce(ctx, client, url, token)
+	})
+}
+
+// sendWithRetries sends a request to url through send, counted against
+// the rate limit of resource. It waits out an exhausted rate limit before
+// sending, and once more when a request is refused because the quota ran
+// out in the meantime. Secondary rate limits are retried up to
+// --max-retries times after their Retry-After, and transient network
+// errors up to --network-retries times.
+func sendWithRetries(ctx context.Context, url, resource string, send func() (int, http.Header, []byte, error)) (int, http.Header, []byte, error) {
+	waitedForReset := false
+	netRetries := 0
+	for retries := 0; ; {
+		if err := waitForQuota(ctx, resource); err != nil {
+			return 0, nil, nil, err
+		}
+		status, header, body, err := send()
+		if err != nil {
+			if netRetries < networkRetries && isTransientNetError(ctx, err) {
+				netRetries++
+				wait := retryBackoff.wait(netRetries)
+				logWarnf("  ↻ %v, retry %d/%d in %s", err, netRetries, networkRetries, wait.Round(time.Millisecond))
+				if err := sleepCtx(ctx, wait); err != nil {
+					return 0, nil, nil, err
+				}
+				continue
+			}
+			return status, header, body, err
+		}
+		if isRateLimited(status, header) && !waitedForReset {
+			waitedForReset = true
+			continue
+		}
+		if isSecondaryLimited(status, header, body) && retries < maxRetries {
+			retries++
+			wait := retryAfter(header, retries)
+			logWarnf("⏸️  Secondary rate limit on %s, retry %d/%d in %s", url, retries, maxRetries, wait.Round(time.Millisecond))
+			if err := sleepCtx(ctx, wait); err != nil {
+				return 0, nil, nil, err
+			}
+			continue
+		}
+		return status, header, body, err
+	}
+}
+
+// networkRetries is how often a request failing with a transient network
+// error is retried, waiting as retryBackoff says; set from
+// --network-retries.
+var networkRetries = 3
+
+// isTransientNetError reports whether a failed request may succeed when
+// sent again: timeouts, connection resets and responses cut short. A host
+// that doesn't resolve or refuses the connection, a bad certificate or URL
+// won't change on a retry, and nothing is retried once ctx is done.
+func isTransientNetError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func getOnce(ctx context.Context, client *http.Client, url string, token string) (int, http.Header, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("User-Agent", "gitlore-enricher")
+	req.Header.Set("User-Agent", userAgent())
+	if apiVersion != "" {
+		req.Header.Set("X-GitHub-Api-Version", apiVersion)
+	}
+	cached, haveCached := loadCached(url, token)
+	if haveCached {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, nil, err
 	}
 	defer resp.Body.Close()
+	recordRateLimit(resp.Header)
+
+	// Unchanged since it was cached; 304s don't count against the quota
+	if resp.StatusCode == http.StatusNotModified && haveCached {
+		return http.StatusOK, cached.header(), cached.Body, nil
+	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return resp.StatusCode, nil, err
+		return resp.StatusCode, resp.Header, nil, err
 	}
-	return resp.StatusCode, body, nil
+	if resp.StatusCode == http.StatusOK {
+		storeCached(url, token, resp.Header, body)
+	}
+	return resp.StatusCode, resp.Header, body, nil
 }
 
-func fetchAllAccessibleRepos(client *http.Client, token string) ([]ghRepo, error) {
-	perPage := 100
-	page := 1
-	aff := "owner,collaborator,organization_member"
+var lastPageRe = regexp.MustCompile(`[?&]page=(\d+)[^>]*>;\s*rel="last"`)
 
-	var all []ghRepo
-	for {
-		url := fmt.Sprintf("https://api.github.com/user/repos?per_page=%d&page=%d&sort=updated&affiliation=%s",
-			perPage, page, aff)
+// lastPageFromLink returns the page number of the rel="last" entry of a
+// Link header, or 0 when there is none (i.e. everything fit on one page).
+func lastPageFromLink(link string) int {
+	m := lastPageRe.FindStringSubmatch(link)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
 
-		status, body, err := doGET(client, url, token)
-		if err != nil {
-			return nil, err
+// countViaLink counts the items of a list endpoint with a single request:
+// fetched with per_page=1, the last page number is the item count. url
+// must already carry per_page=1.
+func countViaLink(ctx context.Context, client *http.Client, token, url string) (int, error) {
+	status, header, body, err := doGETWithHeaders(ctx, client, url, token)
+	if err != nil {
+		return 0, err
+	}
+	if status < 200 || status >= 300 {
+		return 0, fmt.Errorf("count error %d", status)
+	}
+	if n := lastPageFromLink(header.Get("Link")); n > 0 {
+		return n, nil
+	}
+	if len(body) == 0 {
+		return 0, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// listingWorkers bounds the pages of the repo list fetched at once.
+const listingWorkers = 4
+
+// fetchAllAccessibleRepos lists the repos of path a page at a time. The
+// first page's Link header gives the last page, so the rest are fetched
+// concurrently and reassembled in order; without one, pages are followed
+// until an empty one.
+func fetchAllAccessibleRepos(ctx context.Context, client *http.Client, token, path string) ([]ghRepo, error) {
+	pageURL := func(page int) string {
+		return apiURL(fmt.Sprintf("%s&per_page=100&page=%d&sort=updated", path, page))
+	}
+
+	all, n, header, err := fetchRepoPage(ctx, client, token, pageURL(1))
+	if err != nil || n == 0 {
+		return all, err
+	}
+
+	last := lastPageFromLink(header.Get("Link"))
+	if last == 0 {
+		for page := 2; ; page++ {
+			pageRepos, n, _, err := fetchRepoPage(ctx, client, token, pageURL(page))
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				return all, nil
+			}
+			all = append(all, pageRepos...)
 		}
-		if status < 200 || status >= 300 {
-			return nil, fmt.Errorf("github api error %d: %s", status, string(body))
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	pages := make([][]ghRepo, last+1)
+	jobs := make(chan int, last)
+	var wg sync.WaitGroup
+	for w := 0; w < min(listingWorkers, last-1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for page := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				pageRepos, _, _, err := fetchRepoPage(ctx, client, token, pageURL(page))
+				if err != nil {
+					cancel(err)
+					continue
+				}
+				pages[page] = pageRepos
+			}
+		}()
+	}
+	for page := 2; page <= last; page++ {
+		jobs <- page
+	}
+	close(jobs)
+	wg.Wait()
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+
+	for _, pageRepos := range pages[2:] {
+		all = append(all, pageRepos...)
+	}
+	return all, nil
+}
+
+// fetchRepoPage fetches one page of a repo list, returning its repos, the
+// number of entries on the page (malformed ones included) and the headers.
+func fetchRepoPage(ctx context.Context, client *http.Client, token, url string) ([]ghRepo, int, http.Header, error) {
+	status, header, body, err := doGETWithHeaders(ctx, client, url, token)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if status < 200 || status >= 300 {
+		return nil, 0, nil, fmt.Errorf("github api error %d: %s", status, string(body))
+	}
+
+	repos, n, err := decodeRepoPage(body)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return repos, n, header, nil
+}
+
+// decodeRepoPage decodes a page of the repo listing one repo at a time, so
+// that a repo GitHub returns in an unexpected shape (a field changing type,
+// say) is reported and skipped instead of failing the whole run. n is the
+// number of entries on the page, skipped ones included. Null nested objects
+// decode as empty; a null owner (a ghost account) is recovered from the
+// full name.
+func decodeRepoPage(body []byte) (repos []ghRepo, n int, err error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, 0, err
+	}
+	for _, item := range raw {
+		var r ghRepo
+		if err := json.Unmarshal(item, &r); err != nil {
+			logWarnf("⚠️  Skipping a repository in an unexpected format: %v", err)
+			continue
+		}
+		if r.Owner.Login == "" {
+			r.Owner.Login = ownerOf(r.FullName)
+		}
+		repos = append(repos, r)
+	}
+	return repos, len(raw), nil
+}
+
+// filterListing drops the forks, archived and private repos that
+// --include-forks, --include-archived and --include-private exclude, so
+// they cost no enrichment calls and don't count in the summary.
+func filterListing(repos []ghRepo, opts options) []ghRepo {
+	kept := repos[:0]
+	for _, r := range repos {
+		if (r.Fork && !opts.IncludeForks) || (r.Archived && !opts.IncludeArchived) || (r.Private && !opts.IncludePrivate) {
+			continue
 		}
+		kept = append(kept, r)
+	}
+	return kept
+}
 
-		var pageRepos []ghRepo
-		if err := json.Unmarshal(body, &pageRepos); err != nil {
-			return nil, err
+// filterPushedSince keeps the repos pushed to at or after since, those
+// whose push date is missing or unreadable, and archived ones named in keep.
+func filterPushedSince(repos []ghRepo, since time.Time, keep map[string]outRepo) []ghRepo {
+	kept := repos[:0]
+	for _, r := range repos {
+		if _, ok := keep[r.FullName]; ok && r.Archived {
+			kept = append(kept, r)
+			continue
 		}
-		if len(pageRepos) == 0 {
-			break
+		if pushed, err := time.Parse(time.RFC3339, r.PushedAt); err == nil && pushed.Before(since) {
+			continue
 		}
-		all = append(all, pageRepos...)
-		page++
+		kept = append(kept, r)
 	}
-	return all, nil
+	return kept
 }
 
-func fetchLastCommit(client *http.Client, token, fullName string) (string, string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/commits?per_page=1", fullName)
-	status, body, err := doGET(client, url, token)
+// filterSize keeps the repos between minKB and maxKB; a maxKB of 0 is no
+// upper bound.
+func filterSize(repos []ghRepo, minKB, maxKB int) []ghRepo {
+	kept := repos[:0]
+	for _, r := range repos {
+		if r.SizeKB < minKB || (maxKB > 0 && r.SizeKB > maxKB) {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// filterNames applies --include and --exclude: with includes, a repo must
+// match one of them, and matching an exclude drops it regardless.
+func filterNames(repos []ghRepo, include, exclude []string) []ghRepo {
+	kept := repos[:0]
+	for _, r := range repos {
+		if (len(include) > 0 && !matchesAnyGlob(r.FullName, include)) || matchesAnyGlob(r.FullName, exclude) {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// matchesAnyGlob reports whether fullName matches one of the globs, case
+// insensitively like GitHub names. A glob without a "/" is matched against
+// the name alone, since path.Match's * doesn't cross the slash.
+func matchesAnyGlob(fullName string, globs []string) bool {
+	fullName = strings.ToLower(fullName)
+	_, name, _ := strings.Cut(fullName, "/")
+	for _, g := range globs {
+		g = strings.ToLower(g)
+		target := fullName
+		if !strings.Contains(g, "/") {
+			target = name
+		}
+		if ok, _ := path.Match(g, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ownerOf is the owner part of an "owner/name" full name.
+func ownerOf(fullName string) string {
+	owner, _, _ := strings.Cut(fullName, "/")
+	return owner
+}
+
+// errRepoEmpty reports a 409 (or 404) from the commits list: the repo has no
+// commits (yet), e.g. it was just created or an import is still running.
+var errRepoEmpty = errors.New("repository is empty")
+
+type lastCommit struct {
+	SHA     string
+	Date    string
+	Message string
+}
+
+func fetchLastCommit(ctx context.Context, client *http.Client, token, fullName string) (lastCommit, error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/commits?per_page=1", fullName))
+	status, body, err := doGET(ctx, client, url, token)
 	if err != nil {
-		return "", "", err
+		return lastCommit{}, err
+	}
+	if status == 409 || status == 404 {
+		return lastCommit{}, errRepoEmpty
 	}
 	if status < 200 || status >= 300 {
-		return "", "", fmt.Errorf("commits list error %d", status)
+		return lastCommit{}, fmt.Errorf("commits list error %d", status)
 	}
 
 	var commits []commitListItem
 	if err := json.Unmarshal(body, &commits); err != nil {
-		return "", "", err
+		return lastCommit{}, err
 	}
 	if len(commits) == 0 {
-		return "", "", nil
+		return lastCommit{}, nil
 	}
 
-	msg := commits[0].Commit.Message
+	return lastCommit{
+		SHA:     commits[0].SHA,
+		Date:    commits[0].Commit.Author.Date,
+		Message: shortCommitMessage(commits[0].Commit.Message),
+	}, nil
+}
+
+// shortCommitMessage cuts a commit message to the 100 bytes the index keeps,
+// backing up to a rune boundary so a multi-byte character isn't split.
+func shortCommitMessage(msg string) string {
 	if len(msg) > 100 {
-		msg = msg[:100] + "..."
+		cut := 100
+		for cut > 0 && !utf8.RuneStart(msg[cut]) {
+			cut--
+		}
+		msg = msg[:cut] + "..."
 	}
-
-	return commits[0].Commit.Author.Date, msg, nil
+	return msg
 }
 
-func fetchCommitActivity52W(client *http.Client, token, fullName string) ([]weeklyStat, bool, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/stats/commit_activity", fullName)
-
-	backoffs := []time.Duration{700 * time.Millisecond, 1200 * time.Millisecond, 2000 * time.Millisecond, 3000 * time.Millisecond}
-	for attempt := 0; attempt <= len(backoffs); attempt++ {
-		status, body, e := doGET(client, url, token)
+// fetchStats polls a /stats endpoint through its 202s, GitHub computing
+// the data in the background, as statsBackoff says, and decodes the result
+// into v. pending is true when GitHub was still computing after the last
+// poll; v is left untouched then.
+func fetchStats(ctx context.Context, client *http.Client, token, url, name string, v interface{}) (pending bool, err error) {
+	for attempt := 0; attempt <= statsBackoff.attempts; attempt++ {
+		status, body, e := doGET(ctx, client, url, token)
 		if e != nil {
-			return nil, false, e
+			return false, e
 		}
 
 		if status == 202 {
-			if attempt == len(backoffs) {
-				return nil, true, nil
+			if attempt == statsBackoff.attempts {
+				return true, nil
+			}
+			if err := sleepCtx(ctx, statsBackoff.wait(attempt+1)); err != nil {
+				return false, err
 			}
-			time.Sleep(backoffs[attempt])
 			continue
 		}
 
 		if status < 200 || status >= 300 {
-			return nil, false, fmt.Errorf("commit_activity error %d", status)
+			return false, fmt.Errorf("%s error %d", name, status)
 		}
+		return false, json.Unmarshal(body, v)
+	}
 
-		var weeks []weeklyStat
-		if err := json.Unmarshal(body, &weeks); err != nil {
-			return nil, false, err
-		}
-		return weeks, false, nil
+	return true, nil
+}
+
+func fetchCommitActivity52W(ctx context.Context, client *http.Client, token, fullName string) ([]weeklyStat, bool, error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/stats/commit_activity", fullName))
+	var weeks []weeklyStat
+	pending, err := fetchStats(ctx, client, token, url, "commit_activity", &weeks)
+	if err != nil {
+		return nil, false, err
 	}
+	return weeks, pending, nil
+}
 
-	return nil, true, nil
+// participation is /stats/participation: weekly commit counts over 52
+// weeks by everyone and by the repo owner alone.
+type participation struct {
+	All   []int `json:"all"`
+	Owner []int `json:"owner"`
 }
 
-func fetchLanguages(client *http.Client, token, fullName string) (map[string]int, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/languages", fullName)
-	status, body, err := doGET(client, url, token)
+func fetchParticipation(ctx context.Context, client *http.Client, token, fullName string) (participation, bool, error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/stats/participation", fullName))
+	var p participation
+	pending, err := fetchStats(ctx, client, token, url, "participation", &p)
+	if err != nil {
+		return participation{}, false, err
+	}
+	return p, pending, nil
+}
+
+// fetchLifetimeCommits sums the all-time commit totals of every
+// contributor in /stats/contributors, polled through its 202s like the
+// other stats.
+func fetchLifetimeCommits(ctx context.Context, client *http.Client, token, fullName string) (int, bool, error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/stats/contributors", fullName))
+	var rows []struct {
+		Total int `json:"total"`
+	}
+	pending, err := fetchStats(ctx, client, token, url, "contributor_stats", &rows)
+	if err != nil || pending {
+		return 0, pending, err
+	}
+	total := 0
+	for _, row := range rows {
+		total += row.Total
+	}
+	return total, false, nil
+}
+
+// fetchCodeFrequency52W returns the additions and deletions of the last 52
+// weeks. GitHub reports the whole history as [week, additions, -deletions]
+// triples; deletions are made positive here.
+func fetchCodeFrequency52W(ctx context.Context, client *http.Client, token, fullName string) ([]weeklyCodeFrequency, bool, error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/stats/code_frequency", fullName))
+	var rows [][3]int64
+	pending, err := fetchStats(ctx, client, token, url, "code_frequency", &rows)
+	if err != nil || pending {
+		return nil, pending, err
+	}
+
+	rows = rows[max(len(rows)-52, 0):]
+	weeks := make([]weeklyCodeFrequency, len(rows))
+	for i, row := range rows {
+		weeks[i] = weeklyCodeFrequency{Week: row[0], Additions: int(row[1]), Deletions: int(-row[2])}
+	}
+	return weeks, false, nil
+}
+
+// errRepoNotFound reports a 404 for a repo that was in the listing, i.e.
+// one deleted (or made inaccessible) while the run was in progress.
+var errRepoNotFound = errors.New("repository not found")
+
+func fetchLanguages(ctx context.Context, client *http.Client, token, fullName string) (map[string]int, error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/languages", fullName))
+	status, body, err := doGET(ctx, client, url, token)
 	if err != nil {
 		return nil, err
 	}
+	if status == 404 {
+		return nil, errRepoNotFound
+	}
 	if status < 200 || status >= 300 {
 		return nil, fmt.Errorf("languages error %d", status)
 	}
@@ -332,285 +1142,658 @@ func fetchLanguages(client *http.Client, token, fullName string) (map[string]int
 	return langs, nil
 }
 
-func fetchContributors(client *http.Client, token, fullName string) ([]contributor, int, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/contributors?per_page=10", fullName)
-	status, body, err := doGET(client, url, token)
-	if err != nil {
-		return nil, 0, err
-	}
-	if status < 200 || status >= 300 {
-		return nil, 0, fmt.Errorf("contributors error %d", status)
-	}
-
+// contributorsPerPage is the largest per_page the contributors endpoint
+// honors.
+const contributorsPerPage = 100
+
+// fetchContributors returns the top limit contributors by contributions
+// and the repo's total contributor count, anonymous (email-only)
+// contributors included. Pages are fetched until limit is reached but
+// never more than maxPages, so a repo with tens of thousands of
+// contributors can't page on forever; the total comes from the Link
+// header of a one-per-page request, without fetching every page.
+func fetchContributors(ctx context.Context, client *http.Client, token, fullName string, limit, maxPages int) ([]contributor, int, error) {
+	perPage := min(limit, contributorsPerPage)
 	var contribs []contributor
-	if err := json.Unmarshal(body, &contribs); err != nil {
-		return nil, 0, err
+	for page := 1; page <= maxPages && len(contribs) < limit; page++ {
+		url := apiURL(fmt.Sprintf("/repos/%s/contributors?per_page=%d&page=%d", fullName, perPage, page))
+		status, body, err := doGET(ctx, client, url, token)
+		if err != nil {
+			return nil, 0, err
+		}
+		if status < 200 || status >= 300 {
+			return nil, 0, fmt.Errorf("contributors error %d", status)
+		}
+		// 204 No Content: the repo is empty
+		if len(body) == 0 {
+			break
+		}
+
+		var batch []contributor
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, 0, err
+		}
+		contribs = append(contribs, batch...)
+		if len(batch) < perPage {
+			break
+		}
 	}
 
-	// Total count can be derived from pagination, but for simplicity we'll use what we got
+	// GitHub lists contributors by contributions already; sort anyway so
+	// the cut below keeps the top ones whatever order the pages came in
+	sort.SliceStable(contribs, func(i, j int) bool { return contribs[i].Contributions > contribs[j].Contributions })
 	total := len(contribs)
-	if len(contribs) == 10 {
-		// There might be more, but we cap at top 10 for display
-		total = 10
+	if len(contribs) > limit {
+		contribs = contribs[:limit]
+	}
+
+	if total > 0 {
+		// The signed-in list leaves out anonymous contributors and stops at
+		// the cap; count everyone with anon=true
+		countURL := apiURL(fmt.Sprintf("/repos/%s/contributors?per_page=1&anon=true", fullName))
+		n, err := countViaLink(ctx, client, token, countURL)
+		if err != nil {
+			return nil, 0, err
+		}
+		if n > total {
+			total = n
+		}
 	}
 
 	return contribs, total, nil
 }
 
+type communityProfile struct {
+	HealthPercentage int `json:"health_percentage"`
+	Files            struct {
+		CodeOfConduct *struct{} `json:"code_of_conduct"`
+		Contributing  *struct{} `json:"contributing"`
+	} `json:"files"`
+}
+
+// fetchCommunityProfile returns the repo's community health profile. found
+// is false when GitHub has no profile for the repo (404), which is normal
+// for private repos and forks.
+func fetchCommunityProfile(ctx context.Context, client *http.Client, token, fullName string) (communityProfile, bool, error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/community/profile", fullName))
+	status, body, err := doGET(ctx, client, url, token)
+	if err != nil {
+		return communityProfile{}, false, err
+	}
+	if status == 404 {
+		return communityProfile{}, false, nil
+	}
+	if status < 200 || status >= 300 {
+		return communityProfile{}, false, fmt.Errorf("community profile error %d", status)
+	}
+
+	var profile communityProfile
+	if err := json.Unmarshal(body, &profile); err != nil {
+		return communityProfile{}, false, err
+	}
+	return profile, true, nil
+}
+
 func main() {
-	_ = godotenv.Load()
-	token := mustToken()
+	if len(os.Args) > 1 && os.Args[1] == "query" {
+		if err := runQuery(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	opts, err := parseOptions(os.Args[1:])
+	if err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		os.Exit(2)
+	}
 
-	fmt.Println("🔍 Fetching accessible repositories...")
-	repos, err := fetchAllAccessibleRepos(client, token)
+	if opts.Version {
+		fmt.Println(versionString())
+		return
+	}
+
+	if opts.SchemaDiff != "" {
+		if err := runSchemaDiff(opts.SchemaDiff); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	progress, err := newProgressEmitter(opts.ProgressJSON)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer progress.Close()
+	if opts.Stdout != "" {
+		// stdout carries the JSON; keep it clean
+		logOut = os.Stderr
+	}
+	if progress != nil {
+		logOut = io.Discard
+	}
+	logger = newLogger(logOut, opts.LogFormat, opts.logLevel)
+
+	apiVersion = opts.APIVersion
+	baseURL = strings.TrimSuffix(opts.BaseURL, "/")
+	maxRetries, maxRetryWait = opts.MaxRetries, opts.MaxRetryWait
+	networkRetries = opts.NetworkRetries
+	retryBackoff = backoffPolicy{base: opts.RetryBase, factor: opts.RetryFactor, max: opts.RetryMax}
+	statsBackoff = retryBackoff
+	statsBackoff.attempts = opts.StatsPolls
+	cacheDir = opts.Cache
+	requestDelay = opts.RequestDelay
+	outputLocation = opts.location
+	if err := setOutputDir(opts.OutputDir); err != nil {
+		fmt.Fprintf(os.Stderr, "--output-dir: %v\n", err)
+		os.Exit(1)
 	}
-	fmt.Printf("✓ Found %d repositories\n\n", len(repos))
 
-	// Base output objects
-	out := make([]outRepo, 0, len(repos))
-	for _, r := range repos {
-		license := ""
-		if r.License.Key != "" {
-			license = r.License.Name
+	var where whereExpr
+	if opts.Where != "" {
+		if where, err = parseWhere(opts.Where); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
 		}
+	}
 
-		out = append(out, outRepo{
-			Name:          r.Name,
-			FullName:      r.FullName,
-			Description:   r.Description,
-			Private:       r.Private,
-			Fork:          r.Fork,
-			Archived:      r.Archived,
-			Disabled:      r.Disabled,
-			Language:      r.Language,
-			Topics:        r.Topics,
-			Homepage:      r.Homepage,
-			DefaultBranch: r.DefaultBranch,
-			SizeKB:        r.SizeKB,
-			SizeReadable:  humanSizeFromKB(r.SizeKB),
-			Stars:         r.StargazersCount,
-			Forks:         r.ForksCount,
-			Watchers:      r.WatchersCount,
-			OpenIssues:    r.OpenIssuesCount,
-			CreatedAt:     r.CreatedAt,
-			UpdatedAt:     r.UpdatedAt,
-			PushedAt:      r.PushedAt,
-			HTMLURL:       r.HTMLURL,
-			OwnerLogin:    r.Owner.Login,
-			OwnerType:     r.Owner.Type,
-			License:       license,
-			HasIssues:     r.HasIssues,
-			HasProjects:   r.HasProjects,
-			HasWiki:       r.HasWiki,
-			HasPages:      r.HasPages,
-			HasDownloads:  r.HasDownloads,
-		})
+	// Read before the run, which may overwrite it
+	var compareWith []outRepo
+	if opts.Compare != "" {
+		if compareWith, err = loadPreviousIndex(opts.Compare); err == nil && compareWith == nil {
+			err = fmt.Errorf("no index at %s", opts.Compare)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--compare: %v\n", err)
+			os.Exit(2)
+		}
 	}
 
-	// Enrich concurrently
-	fmt.Println("🔧 Enriching repositories with detailed data...")
-	workers := 6 // Reduced to be gentler on rate limits
-	jobs := make(chan int, len(out))
-	var wg sync.WaitGroup
-	var mu sync.Mutex
+	var annotations map[string]map[string]string
+	if opts.Annotations != "" {
+		if annotations, err = loadAnnotations(opts.Annotations); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
 
-	completed := 0
-	total := len(out)
+	envSource, err := loadEnvFile(opts.EnvFile, opts.EnvOverrides)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	token, tokenSource, err := loadToken(opts.TokenFile, envSource)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logInfof("🔑 Using the token from %s", tokenSource)
 
-	for w := 0; w < workers; w++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for i := range jobs {
-				full := out[i].FullName
-
-				// 1) Last commit + message
-				lastDate, lastMsg, e := fetchLastCommit(client, token, full)
-				if e == nil {
-					out[i].LastCommitAt = lastDate
-					out[i].LastCommitMessage = lastMsg
-				}
+	client := &http.Client{Timeout: 30 * time.Second}
 
-				// 2) 52w activity stats
-				weeks, pending, e2 := fetchCommitActivity52W(client, token, full)
-				if e2 == nil {
-					out[i].WeeklyStats52W = weeks
-					out[i].StatsCachePending = pending
-
-					// Extract simple totals
-					totals := make([]int, len(weeks))
-					totalCommits := 0
-					for idx, w := range weeks {
-						totals[idx] = w.Total
-						totalCommits += w.Total
-					}
-					out[i].WeeklyCommits52W = totals
-					out[i].TotalCommits = totalCommits
-				}
+	// Ctrl-C or --timeout stops new work; whatever was fetched is still
+	// written out
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if opts.Timeout > 0 && opts.Serve == "" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
 
-				// 3) Language breakdown
-				langs, e3 := fetchLanguages(client, token, full)
-				if e3 == nil && len(langs) > 0 {
-					out[i].LanguageBreakdown = langs
-				}
+	if opts.ReplayErrors != "" {
+		if err := runReplayErrors(ctx, client, token, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if opts.ResumePending {
+		if err := runResumePending(ctx, client, token, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-				// 4) Contributors (top 10)
-				contribs, count, e4 := fetchContributors(client, token, full)
-				if e4 == nil {
-					out[i].TopContributors = contribs
-					out[i].ContributorCount = count
-				}
+	progress.emit(progressEvent{Event: "run-start"})
 
-				mu.Lock()
-				completed++
-				if completed%5 == 0 || completed == total {
-					fmt.Printf("  Progress: %d/%d repositories enriched\n", completed, total)
-				}
-				mu.Unlock()
+	me, err := fetchAuthenticatedUser(ctx, client, token)
+	if err != nil {
+		logWarnf("⚠️  Could not identify the authenticated user: %v", err)
+	} else {
+		logInfof("👤 Authenticated as %s", me.Login)
+	}
 
-				// Small delay to respect rate limits
-				time.Sleep(100 * time.Millisecond)
-			}
-		}()
+	if opts.Events {
+		if err := runEventsMode(ctx, client, token, opts, me); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	for i := range out {
-		jobs <- i
+	if opts.Serve != "" {
+		run := func(ctx context.Context) ([]outRepo, summary, error) {
+			account, repos, err := selectRepos(ctx, client, token, opts, me)
+			if err != nil {
+				return nil, summary{}, err
+			}
+			out, sum, _, err := collect(ctx, client, token, opts, me, account, repos, progress, where, annotations, nil)
+			if err != nil {
+				return nil, summary{}, err
+			}
+			// A cancelled or timed-out run is partial; keep serving the last one
+			if err := ctx.Err(); err != nil {
+				return nil, summary{}, err
+			}
+			return out, sum, nil
+		}
+		if err := runServer(ctx, opts, run); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
-	close(jobs)
-	wg.Wait()
 
-	fmt.Println("\n📊 Building summary...")
+	progress.phase("listing")
+	account, repos, err := selectRepos(ctx, client, token, opts, me)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	// Build comprehensive summary
-	var sum summary
-	sum.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
-	sum.Languages = map[string]int{}
-	sum.Topics = map[string]int{}
-	sum.Licenses = map[string]int{}
+	if opts.DryRun {
+		printDryRun(repos, opts)
+		return
+	}
 
-	var newestUpdate, newestPush, oldestCreated, oldestUpdate time.Time
-	var hasUpdate, hasPush, hasCreated, hasOldUpdate bool
+	var done func(*outRepo)
+	var stream *indexStream
+	if opts.Format == "jsonl" {
+		if stream, err = newIndexStream(indexJSONLPath, opts, where); err != nil {
+			fatalWrite(indexJSONLPath, err)
+		}
+		done = stream.write
+	}
+	out, sum, enrichErrors, err := collect(ctx, client, token, opts, me, account, repos, progress, where, annotations, done)
+	if stream != nil {
+		if err := stream.Close(); err != nil {
+			fatalWrite(indexJSONLPath, err)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	for _, r := range out {
-		sum.RepoCounts.Total++
+	// Write JSON files
+	progress.phase("writing")
+	logInfof("\n💾 Writing output files...")
 
-		if r.Private {
-			sum.RepoCounts.Private++
-		} else {
-			sum.RepoCounts.Public++
-		}
+	summaryJSON, _ := json.MarshalIndent(sum, "", "  ")
 
-		if r.Archived {
-			sum.RepoCounts.Archived++
+	// A streamed index is already written
+	var indexJSON []byte
+	if stream == nil {
+		indexJSON, _ = json.MarshalIndent(out, "", "  ")
+		if opts.fieldList != nil {
+			projected, err := projectFields(out, opts.fieldList)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "--fields: %v\n", err)
+				os.Exit(1)
+			}
+			indexJSON, _ = json.MarshalIndent(projected, "", "  ")
 		}
 
-		if r.Fork {
-			sum.RepoCounts.Forks++
+		if err := checkOutputSize(len(indexJSON), opts.MaxOutputMB, opts.HardMaxOutputMB); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+	}
 
-		if r.OwnerType == "Organization" {
-			sum.RepoCounts.Org++
+	var ownerIndexes []string
+	switch opts.Stdout {
+	case "index":
+		os.Stdout.Write(append(indexJSON, '\n'))
+	case "summary":
+		os.Stdout.Write(append(summaryJSON, '\n'))
+	default:
+		if opts.SplitByOwner {
+			var err error
+			if ownerIndexes, err = writeOwnerIndexes(out, opts.fieldList); err != nil {
+				fatalWrite("the per-owner indexes", err)
+			}
+			if err := os.WriteFile(summaryPath, summaryJSON, 0644); err != nil {
+				fatalWrite(summaryPath, err)
+			}
+		} else if opts.Combined != "" {
+			if err := writeCombined(opts.Combined, sum, indexJSON); err != nil {
+				fatalWrite(opts.Combined, err)
+			}
 		} else {
-			sum.RepoCounts.User++
+			if opts.Format != "csv" && stream == nil {
+				if err := os.WriteFile(indexPath, indexJSON, 0644); err != nil {
+					fatalWrite(indexPath, err)
+				}
+			}
+			if opts.Format == "csv" || opts.Format == "both" {
+				csvData, err := indexCSV(out, opts.fieldList)
+				if err == nil {
+					err = os.WriteFile(indexCSVPath, csvData, 0644)
+				}
+				if err != nil {
+					fatalWrite(indexCSVPath, err)
+				}
+			}
+			if err := os.WriteFile(summaryPath, summaryJSON, 0644); err != nil {
+				fatalWrite(summaryPath, err)
+			}
 		}
+		if opts.Format == "markdown" {
+			if err := os.WriteFile(reportPath, markdownReport(sum, out), 0644); err != nil {
+				fatalWrite(reportPath, err)
+			}
+		}
+		if opts.Format == "prometheus" {
+			// Atomic, as a textfile collector may read it at any time
+			if err := writeFileAtomic(metricsPath, promMetrics(sum, out)); err != nil {
+				fatalWrite(metricsPath, err)
+			}
+		}
+		if err := writeErrorsFile(errorsPath, enrichErrors); err != nil {
+			logWarnf("⚠️  Could not write %s: %v", errorsPath, err)
+		}
+		if err := writeErrorsFile(pendingPath, statsPendingOf(out)); err != nil {
+			logWarnf("⚠️  Could not write %s: %v", pendingPath, err)
+		}
+	}
 
-		sum.Size.TotalKB += r.SizeKB
-		sum.Engagement.TotalStars += r.Stars
-		sum.Engagement.TotalForks += r.Forks
-		sum.Engagement.TotalWatchers += r.Watchers
-		sum.Engagement.TotalCommits += r.TotalCommits
-
-		if r.Language != "" {
-			sum.Languages[r.Language]++
+	if opts.Compare != "" {
+		ch := compareIndexes(opts.Compare, compareWith, out)
+		data, _ := json.MarshalIndent(ch, "", "  ")
+		if err := os.WriteFile(changesPath, data, 0644); err != nil {
+			fatalWrite(changesPath, err)
 		}
+		logInfof("🔀 Wrote %s: %d added, %d removed, %d changed since %s", changesPath, len(ch.Added), len(ch.Removed), len(ch.Changed), opts.Compare)
+	}
 
-		for _, topic := range r.Topics {
-			sum.Topics[topic]++
+	if opts.SQLite != "" {
+		if err := writeSQLite(opts.SQLite, out, sum); err != nil {
+			fatalWrite(opts.SQLite, err)
 		}
+		logInfof("🗄️  Wrote the SQLite database %s", opts.SQLite)
+	}
 
-		if r.License != "" {
-			sum.Licenses[r.License]++
+	if opts.Graph != "" {
+		if err := writeContributorGraph(opts.Graph, out); err != nil {
+			logWarnf("⚠️  Could not write %s: %v", opts.Graph, err)
+		} else {
+			logInfof("🕸️  Wrote the contributor graph to %s", opts.Graph)
 		}
+	}
 
-		// Timestamps
-		if t, err := time.Parse(time.RFC3339, r.UpdatedAt); err == nil {
-			if !hasUpdate || t.After(newestUpdate) {
-				newestUpdate = t
-				hasUpdate = true
-			}
-			if !hasOldUpdate || t.Before(oldestUpdate) {
-				oldestUpdate = t
-				hasOldUpdate = true
-			}
+	if opts.GitHubOutput {
+		if err := writeGitHubOutput(sum); err != nil {
+			logWarnf("⚠️  Could not write GITHUB_OUTPUT: %v", err)
 		}
+	}
 
-		if t, err := time.Parse(time.RFC3339, r.PushedAt); err == nil {
-			if !hasPush || t.After(newestPush) {
-				newestPush = t
-				hasPush = true
+	if opts.Stdout == "" {
+		logInfof("\n✨ Generated:")
+		if opts.SplitByOwner {
+			logInfof("   📄 index_{owner}.json (%d owners)", len(ownerIndexes))
+			logInfof("   📊 %s", summaryPath)
+		} else if opts.Combined != "" {
+			logInfof("   📦 %s", opts.Combined)
+		} else {
+			switch opts.Format {
+			case "csv":
+				logInfof("   📄 %s", indexCSVPath)
+			case "both":
+				logInfof("   📄 %s", indexPath)
+				logInfof("   📄 %s", indexCSVPath)
+			case "jsonl":
+				logInfof("   📄 %s", indexJSONLPath)
+			default:
+				logInfof("   📄 %s", indexPath)
 			}
+			logInfof("   📊 %s", summaryPath)
+		}
+		if opts.Format == "markdown" {
+			logInfof("   📝 %s", reportPath)
 		}
+		if opts.Format == "prometheus" {
+			logInfof("   📈 %s", metricsPath)
+		}
+		if len(enrichErrors) > 0 {
+			logWarnf("   ⚠️  %s (%d failed calls; retry with --replay-errors)", errorsPath, len(enrichErrors))
+		}
+		if sum.Enrichment.ReposStatsPending > 0 {
+			logInfof("   ⏳ %s (fill in the pending stats later with --resume-pending)", pendingPath)
+		}
+	}
+	logInfof("\n📈 Stats:")
+	logInfof("   Repositories: %d", len(out))
+	logInfof("   Total Stars: %d", sum.Engagement.TotalStars)
+	logInfof("   Total Commits: %d", sum.Engagement.TotalCommits)
+	logInfof("   Stats pending (202): %d", sum.Enrichment.ReposStatsPending)
+	logInfof("")
+
+	progress.emit(progressEvent{Event: "run-complete", Total: len(out)})
+}
 
-		if t, err := time.Parse(time.RFC3339, r.CreatedAt); err == nil {
-			if !hasCreated || t.Before(oldestCreated) {
-				oldestCreated = t
-				hasCreated = true
+// selectRepos lists the repositories of the account the run reports on
+// (the token's own, or --user/--org) and applies the listing filters.
+func selectRepos(ctx context.Context, client *http.Client, token string, opts options, me ghUser) (ghUser, []ghRepo, error) {
+	// The summary's owner is the account reported on
+	account := me
+	if target := cmp.Or(opts.User, opts.Org); target != "" {
+		var err error
+		if account, err = fetchUser(ctx, client, token, target); err != nil {
+			return ghUser{}, nil, err
+		}
+		logInfof("🔍 Fetching the repositories of %s...", account.Login)
+	} else {
+		logInfof("🔍 Fetching accessible repositories...")
+	}
+	repos, err := fetchAllAccessibleRepos(ctx, client, token, listingPath(opts.User, opts.Org))
+	if err != nil {
+		return ghUser{}, nil, err
+	}
+	logInfof("✓ Found %d repositories", len(repos))
+	if !opts.IncludeForks || !opts.IncludeArchived || !opts.IncludePrivate {
+		before := len(repos)
+		repos = filterListing(repos, opts)
+		logInfof("🔎 Keeping %d of %d repositories after the --include-* filters", len(repos), before)
+	}
+	if len(opts.Include) > 0 || len(opts.Exclude) > 0 {
+		before := len(repos)
+		repos = filterNames(repos, opts.Include, opts.Exclude)
+		logInfof("🔎 Selected %d of %d repositories with --include/--exclude", len(repos), before)
+	}
+	if opts.MinSize != "" || opts.MaxSize != "" {
+		before := len(repos)
+		repos = filterSize(repos, opts.minSizeKB, opts.maxSizeKB)
+		logInfof("🔎 Keeping %d of %d repositories within --min-size/--max-size", len(repos), before)
+	}
+	if !opts.since.IsZero() {
+		// Archived repos whose enrichment carries over cost nothing to keep
+		var keep map[string]outRepo
+		if !opts.ReenrichArchived {
+			prev, err := loadPreviousIndex(indexPath)
+			if err != nil {
+				logWarnf("⚠️  Ignoring previous index: %v", err)
 			}
+			keep = reusableArchived(prev, opts)
 		}
+		before := len(repos)
+		repos = filterPushedSince(repos, opts.since, keep)
+		logInfof("🔎 Skipped %d repositories not pushed to since %s", before-len(repos), opts.since.Format(time.RFC3339))
+	}
+	return account, repos, nil
+}
 
-		// Enrichment counters
-		if r.LastCommitAt != "" {
-			sum.Enrichment.ReposWithLastCommit++
+// collect enriches repos and builds the summary of the run, calling done
+// (when non-nil) as each repo is finished. A cancelled run still returns
+// what was collected; the error is for a run that can't produce results.
+func collect(ctx context.Context, client *http.Client, token string, opts options, me, account ghUser, repos []ghRepo, progress *progressEmitter, where whereExpr, annotations map[string]map[string]string, done func(*outRepo)) ([]outRepo, summary, []enrichError, error) {
+	// The calendar is only available for the token's own account
+	wantCalendar := me.Login != "" && account.Login == me.Login
+	graphqlOK := graphqlAvailable(ctx, client, token, opts, wantCalendar)
+
+	// Account-level contribution calendar (one GraphQL call)
+	var calendar contributionCalendar
+	if graphqlOK && wantCalendar {
+		var calErr error
+		if calendar, calErr = fetchContributionCalendar(ctx, client, token); calErr != nil {
+			logWarnf("⚠️  Contribution calendar unavailable: %v", calErr)
 		}
-		if len(r.WeeklyCommits52W) > 0 {
-			sum.Enrichment.ReposWithStats52W++
+	}
+
+	// Base output objects
+	affiliated := opts.User == "" && opts.Org == ""
+	out := make([]outRepo, 0, len(repos))
+	for _, r := range repos {
+		license := ""
+		if r.License.Key != "" {
+			license = r.License.Name
 		}
-		if len(r.LanguageBreakdown) > 0 {
-			sum.Enrichment.ReposWithLanguages++
+
+		out = append(out, outRepo{
+			Name:            r.Name,
+			FullName:        r.FullName,
+			Description:     r.Description,
+			Private:         r.Private,
+			Fork:            r.Fork,
+			Archived:        r.Archived,
+			Disabled:        r.Disabled,
+			Language:        r.Language,
+			Topics:          cleanTopics(r.FullName, r.Topics),
+			Homepage:        r.Homepage,
+			DefaultBranch:   r.DefaultBranch,
+			SizeKB:          r.SizeKB,
+			SizeReadable:    humanSizeFromKB(r.SizeKB),
+			Stars:           r.StargazersCount,
+			Forks:           r.ForksCount,
+			StargazersCount: r.StargazersCount,
+			ForksCount:      r.ForksCount,
+			Watchers:        r.WatchersCount,
+			OpenIssues:      r.OpenIssuesCount,
+			CreatedAt:       localizeTimestamp(r.CreatedAt),
+			UpdatedAt:       localizeTimestamp(r.UpdatedAt),
+			PushedAt:        localizeTimestamp(r.PushedAt),
+			HTMLURL:         r.HTMLURL,
+			OwnerLogin:      r.Owner.Login,
+			OwnerType:       r.Owner.Type,
+			MyRole:          repoRole(r.Owner.Login, r.Owner.Type, me.Login, affiliated),
+			License:         license,
+			HasIssues:       r.HasIssues,
+			HasProjects:     r.HasProjects,
+			HasWiki:         r.HasWiki,
+			HasPages:        r.HasPages,
+			HasDownloads:    r.HasDownloads,
+		})
+	}
+
+	if annotations != nil {
+		applyAnnotations(out, annotations)
+	}
+
+	rankFreshness(out)
+
+	if opts.LocalRoot != "" {
+		applyLocalSizes(out, opts.LocalRoot)
+	}
+
+	if opts.GraphQLEngagement && graphqlOK {
+		names := make([]string, len(out))
+		for i := range out {
+			names[i] = out[i].FullName
 		}
-		if len(r.TopContributors) > 0 {
-			sum.Enrichment.ReposWithContributors++
+		counts, err := fetchGraphQLEngagement(ctx, client, token, names)
+		if err != nil {
+			logWarnf("⚠️  GraphQL engagement incomplete, keeping REST counts where it failed: %v", err)
 		}
-		if r.StatsCachePending {
-			sum.Enrichment.ReposStatsPending++
+		for i := range out {
+			if c, ok := counts[out[i].FullName]; ok {
+				out[i].Stars = c.Stars
+				out[i].StargazersCount = c.Stars
+				out[i].Watchers = c.Watchers
+			}
 		}
 	}
 
-	sum.Size.Human = humanSizeFromKB(sum.Size.TotalKB)
-	if hasUpdate {
-		sum.Activity.MostRecentUpdate = newestUpdate.UTC().Format(time.RFC3339)
+	// Repos the previous run left stats-pending are warm now; do them first
+	prev, err := loadPreviousIndex(indexPath)
+	if err != nil {
+		logWarnf("⚠️  Ignoring previous index: %v", err)
 	}
-	if hasPush {
-		sum.Activity.MostRecentPush = newestPush.UTC().Format(time.RFC3339)
+	pending := pendingFromIndex(prev)
+	if len(pending) > 0 {
+		logInfof("↻ Prioritizing %d repositories left stats-pending by the previous run", len(pending))
 	}
-	if hasCreated {
-		sum.Activity.OldestCreated = oldestCreated.UTC().Format(time.RFC3339)
+
+	// Archived repos can't change, so reuse what the previous run fetched
+	order := enrichmentOrder(out, pending, opts.StatsWarmupOrder)
+	if !opts.ReenrichArchived {
+		order = carryOverArchived(out, prev, order, opts)
 	}
-	if hasOldUpdate {
-		sum.Activity.OldestUpdate = oldestUpdate.UTC().Format(time.RFC3339)
+
+	// One GraphQL query per batch of repos instead of their REST commits
+	// and languages calls; whatever it can't provide stays on REST, and so
+	// does the listing above
+	if opts.API == "graphql" {
+		if !graphqlOK {
+			logWarnf("⚠️  --api=graphql needs GraphQL; enriching over REST")
+		} else {
+			names := make([]string, len(order))
+			for n, i := range order {
+				names[n] = out[i].FullName
+			}
+			data := fetchGraphQLRepoData(ctx, client, token, names)
+			for _, i := range order {
+				if d, ok := data[out[i].FullName]; ok {
+					applyGraphQLRepoData(&out[i], d)
+				}
+			}
+			logInfof("⚡ GraphQL covered %d of %d repositories", len(data), len(order))
+		}
 	}
 
-	// Write JSON files
-	fmt.Println("\n💾 Writing output files...")
+	// Enrich concurrently
+	progress.phase("enriching")
+	logInfof("🔧 Enriching repositories with detailed data...")
+	enrichErrors := enrichAll(ctx, client, token, opts, out, order, progress, done)
+	if opts.Hash {
+		applyContentHashes(out, true)
+	}
 
-	indexJSON, _ := json.MarshalIndent(out, "", "  ")
-	summaryJSON, _ := json.MarshalIndent(sum, "", "  ")
+	if where != nil {
+		before := len(out)
+		var err error
+		if out, err = filterWhere(out, where); err != nil {
+			return nil, summary{}, nil, fmt.Errorf("--where: %w", err)
+		}
+		logInfof("\n🔎 --where kept %d of %d repositories", len(out), before)
+	}
 
-	_ = os.WriteFile("../repos_index_enriched.json", indexJSON, 0644)
-	_ = os.WriteFile("../repos_summary.json", summaryJSON, 0644)
-
-	fmt.Println("\n✨ Generated:")
-	fmt.Println("   📄 repos_index_enriched.json")
-	fmt.Println("   📊 repos_summary.json")
-	fmt.Printf("\n📈 Stats:\n")
-	fmt.Printf("   Repositories: %d\n", len(out))
-	fmt.Printf("   Total Stars: %d\n", sum.Engagement.TotalStars)
-	fmt.Printf("   Total Commits: %d\n", sum.Engagement.TotalCommits)
-	fmt.Printf("   Stats pending (202): %d\n", sum.Enrichment.ReposStatsPending)
-	fmt.Println()
+	progress.phase("summarizing")
+	logInfof("\n📊 Building summary...")
+
+	sum := buildSummary(out, opts)
+	sum.Owner.Login = account.Login
+	sum.Owner.Name = account.Name
+	sum.Owner.Followers = account.Followers
+	sum.Owner.Following = account.Following
+	sum.Owner.PublicRepos = account.PublicRepos
+	sum.Owner.TotalContributions = calendar.TotalContributions
+	sum.Owner.CurrentStreakDays = calendar.CurrentStreakDays
+	sum.Owner.LongestStreakDays = calendar.LongestStreakDays
+	return out, sum, enrichErrors, nil
 }
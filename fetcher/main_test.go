@@ -0,0 +1,250 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"unicode/utf8"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTransientNetError(t *testing.T) {
+	opErr := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: err}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"timeout", opErr(timeoutError{}), true},
+		{"connection reset", opErr(os.NewSyscallError("read", syscall.ECONNRESET)), true},
+		{"unexpected EOF", fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF), true},
+		{"connection refused", opErr(os.NewSyscallError("connect", syscall.ECONNREFUSED)), false},
+		{"no such host", opErr(&net.DNSError{Err: "no such host", Name: "api.example.invalid", IsNotFound: true}), false},
+		{"other", errors.New("x509: certificate signed by unknown authority"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransientNetError(context.Background(), tt.err); got != tt.want {
+			t.Errorf("%s: isTransientNetError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if isTransientNetError(ctx, opErr(timeoutError{})) {
+		t.Error("retrying after the context is done")
+	}
+}
+
+// contributorsAPI serves /repos/o/r/contributors like GitHub: signed
+// contributors by contributions, paged with a Link header, and with
+// anon=true the anonymous ones after them. It counts the list pages asked.
+func contributorsAPI(signed, anon int, pages *atomic.Int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		perPage, _ := strconv.Atoi(q.Get("per_page"))
+		page, _ := strconv.Atoi(cmp.Or(q.Get("page"), "1"))
+		total := signed
+		if q.Get("anon") == "true" {
+			total += anon
+		} else {
+			pages.Add(1)
+		}
+
+		last := (total + perPage - 1) / perPage
+		link := func(p int, rel string) string {
+			u := *r.URL
+			q.Set("page", strconv.Itoa(p))
+			u.RawQuery = q.Encode()
+			return fmt.Sprintf(`<http://%s%s>; rel="%s"`, r.Host, u.RequestURI(), rel)
+		}
+		if page < last {
+			w.Header().Set("Link", link(page+1, "next")+", "+link(last, "last"))
+		}
+
+		var batch []contributor
+		for i := (page - 1) * perPage; i < min(page*perPage, total); i++ {
+			// Served out of order within a page; the fetcher sorts
+			batch = append(batch, contributor{Login: fmt.Sprintf("u%d", i), Contributions: 10000 - i})
+		}
+		slices.Reverse(batch)
+		json.NewEncoder(w).Encode(batch)
+	})
+}
+
+func TestFetchContributorsPaginates(t *testing.T) {
+	tests := []struct {
+		name            string
+		limit, maxPages int
+		wantTop         int
+		wantPages       int64
+	}{
+		{"one page", 10, 5, 10, 1},
+		{"several pages", 250, 5, 250, 3},
+		{"stops at the limit", 150, 5, 150, 2},
+		{"stops at max pages", 250, 1, 100, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages atomic.Int64
+			newTestAPI(t, contributorsAPI(260, 40, &pages))
+
+			top, total, err := fetchContributors(context.Background(), http.DefaultClient, "token", "o/r", tt.limit, tt.maxPages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(top) != tt.wantTop {
+				t.Errorf("%d top contributors, want %d", len(top), tt.wantTop)
+			}
+			// The anon=true count includes everyone, however few pages were read
+			if total != 300 {
+				t.Errorf("total %d, want 300", total)
+			}
+			if got := pages.Load(); got != tt.wantPages {
+				t.Errorf("%d list pages fetched, want %d", got, tt.wantPages)
+			}
+			if !slices.IsSortedFunc(top, func(a, b contributor) int { return b.Contributions - a.Contributions }) || top[0].Login != "u0" {
+				t.Errorf("top contributors not by contributions: first %+v", top[0])
+			}
+		})
+	}
+}
+
+func TestDecodeRepoPageNulls(t *testing.T) {
+	discardLogs(t)
+	page := `[
+		{"full_name":"octo/full","owner":{"login":"octo","type":"User"},"license":{"key":"mit","name":"MIT License","spdx_id":"MIT"}},
+		{"full_name":"ghost/orphan","owner":null,"license":null,"topics":null},
+		{"full_name":"octo/odd","size":"big"}
+	]`
+	repos, n, err := decodeRepoPage([]byte(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || len(repos) != 2 {
+		t.Fatalf("decoded %d of %d entries, want 2 of 3 (the odd one skipped)", len(repos), n)
+	}
+	if repos[0].License.SPDX != "MIT" || repos[0].Owner.Login != "octo" {
+		t.Errorf("octo/full: %+v", repos[0])
+	}
+	orphan := repos[1]
+	if orphan.Owner.Login != "ghost" || orphan.Owner.Type != "" {
+		t.Errorf("null owner decoded as %+v, want login ghost from the full name", orphan.Owner)
+	}
+	if orphan.License.Key != "" || orphan.License.Name != "" || orphan.Topics != nil {
+		t.Errorf("null license and topics decoded as %+v, %v", orphan.License, orphan.Topics)
+	}
+}
+
+func TestShortCommitMessageUTF8(t *testing.T) {
+	a := func(n int) string { return strings.Repeat("a", n) }
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{"short", "fix: typo", "fix: typo"},
+		{"exactly 100 bytes", a(100), a(100)},
+		{"ascii cut", a(150), a(100) + "..."},
+		{"2-byte rune across the cut", a(99) + "é" + a(10), a(99) + "..."},
+		{"3-byte rune across the cut", a(98) + "€" + a(10), a(98) + "..."},
+		{"4-byte rune across the cut", a(97) + "🚀" + a(10), a(97) + "..."},
+		{"rune ending at the cut", a(98) + "é" + a(10), a(98) + "é..."},
+		{"all multi-byte", strings.Repeat("日本", 40), strings.Repeat("日本", 16) + "日..."},
+	}
+	for _, tt := range tests {
+		got := shortCommitMessage(tt.msg)
+		if !utf8.ValidString(got) {
+			t.Errorf("%s: %q is not valid UTF-8", tt.name, got)
+		}
+		if got != tt.want {
+			t.Errorf("%s: shortCommitMessage = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHumanSizeBoundaries(t *testing.T) {
+	tests := []struct {
+		kb   int
+		want string
+	}{
+		{0, "0 B"},
+		{1, "1 KB"},
+		{1023, "1023 KB"},
+		{1024, "1.0 MB"},
+		{1075, "1.0 MB"},
+		{1076, "1.1 MB"},
+		{1048524, "1023.9 MB"},
+		{1048525, "1.0 GB"},
+		{1048575, "1.0 GB"},
+		{1048576, "1.0 GB"},
+		{1073741824, "1.0 TB"},
+		{1 << 40, "1024.0 TB"},
+	}
+	for _, tt := range tests {
+		got := humanSizeFromKB(tt.kb)
+		if got != tt.want {
+			t.Errorf("humanSizeFromKB(%d) = %q, want %q", tt.kb, got, tt.want)
+		}
+
+		// Reading it back is off by at most the rounding to one decimal:
+		// nothing in KB, half a tenth of the unit above
+		back, err := parseHumanSize(got)
+		if err != nil {
+			t.Errorf("parseHumanSize(%q): %v", got, err)
+			continue
+		}
+		_, unit, _ := strings.Cut(got, " ")
+		slack := 0.0
+		if i := slices.Index(sizeUnits, unit); i > 1 {
+			slack = math.Pow(1024, float64(i-1))*0.05 + 0.5
+		}
+		if diff := math.Abs(float64(back - tt.kb)); diff > slack {
+			t.Errorf("%d KB -> %q -> %d KB, off by %.0f", tt.kb, got, back, diff)
+		}
+	}
+}
+
+func TestParseHumanSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"100", 100, false},
+		{"512kb", 512, false},
+		{"1 M", 1024, false},
+		{"1.5 GB", 1572864, false},
+		{".5MB", 512, false},
+		{"2048 B", 2, false},
+		{"1 TB", 1073741824, false},
+		{"1 PB", 0, true},
+		{"big", 0, true},
+		{"-1 KB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseHumanSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseHumanSize(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
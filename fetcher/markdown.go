@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// markdownReport renders a portfolio page: totals and the language
+// distribution from the summary, then a table of the repos by stars.
+func markdownReport(sum summary, out []outRepo) []byte {
+	var b bytes.Buffer
+
+	title := "Repository portfolio"
+	if sum.Owner.Login != "" {
+		title = sum.Owner.Login + "'s repositories"
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "_Generated %s_\n\n", sum.GeneratedAt)
+	fmt.Fprintf(&b, "- **Repositories:** %d (%d public, %d private, %d archived)\n",
+		sum.RepoCounts.Total, sum.RepoCounts.Public, sum.RepoCounts.Private, sum.RepoCounts.Archived)
+	fmt.Fprintf(&b, "- **Stars:** %d\n", sum.Engagement.TotalStars)
+	fmt.Fprintf(&b, "- **Forks:** %d\n", sum.Engagement.TotalForks)
+	fmt.Fprintf(&b, "- **Total size:** %s\n\n", sum.Size.Human)
+
+	if len(sum.Languages) > 0 {
+		langs := make([]string, 0, len(sum.Languages))
+		for lang := range sum.Languages {
+			langs = append(langs, lang)
+		}
+		sort.Slice(langs, func(i, j int) bool {
+			if sum.Languages[langs[i]] != sum.Languages[langs[j]] {
+				return sum.Languages[langs[i]] > sum.Languages[langs[j]]
+			}
+			return langs[i] < langs[j]
+		})
+
+		b.WriteString("## Languages\n\n| Language | Repositories |\n| --- | ---: |\n")
+		for _, lang := range langs {
+			fmt.Fprintf(&b, "| %s | %d |\n", lang, sum.Languages[lang])
+		}
+		b.WriteString("\n")
+	}
+
+	repos := append([]outRepo(nil), out...)
+	sort.SliceStable(repos, func(i, j int) bool {
+		if repos[i].Stars != repos[j].Stars {
+			return repos[i].Stars > repos[j].Stars
+		}
+		return repos[i].FullName < repos[j].FullName
+	})
+
+	b.WriteString("## Repositories\n\n| Repository | Stars | Last commit | Language |\n| --- | ---: | --- | --- |\n")
+	for _, r := range repos {
+		lang := r.Language
+		if lang == "" {
+			lang = "—"
+		}
+		fmt.Fprintf(&b, "| [%s](%s) | %d | %s | %s |\n", r.FullName, r.HTMLURL, r.Stars, reportDate(r.LastCommitAt), lang)
+	}
+	return b.Bytes()
+}
+
+// reportDate shortens a timestamp to its date, or "—" when there is none.
+func reportDate(ts string) string {
+	if ts == "" {
+		return "—"
+	}
+	if t, err := time.Parse(time.RFC3339, ts); err == nil {
+		return t.Format("2006-01-02")
+	}
+	return ts
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// options holds everything configurable from the command line.
+type options struct {
+	SchemaDiff          string
+	ReenrichArchived    bool
+	ProgressJSON        string
+	TopContributors     int
+	ForkCompare         bool
+	StatsWarmupOrder    bool
+	GitHubOutput        bool
+	IssueRatio          bool
+	Where               string
+	Stdout              string
+	MaxOutputMB         float64
+	HardMaxOutputMB     float64
+	StarBuckets         string
+	OpenIssueBuckets    string
+	Annotations         string
+	MergeSettings       bool
+	MergePolicy         string
+	ReplayErrors        string
+	APIVersion          string
+	StatsPushedWithin   string
+	Timings             bool
+	GraphQLEngagement   bool
+	Events              bool
+	Fields              string
+	Combined            string
+	LocalRoot           string
+	PRAuthors           bool
+	Graph               string
+	EnvFile             string
+	EnvOverrides        bool
+	SplitByOwner        bool
+	CIDetect            bool
+	Version             bool
+	RepoRetries         int
+	OutputDir           string
+	WorkflowPermissions bool
+	Hash                bool
+	MaxRetries          int
+	MaxRetryWait        time.Duration
+	Cache               string
+	ContributorPages    int
+	Timezone            string
+	API                 string
+	AllBranches         bool
+	MaxBranches         int
+	BaseURL             string
+	Timeout             time.Duration
+	Workers             int
+	RequestDelay        time.Duration
+	Format              string
+	SQLite              string
+	IncludeForks        bool
+	IncludeArchived     bool
+	IncludePrivate      bool
+	User                string
+	Org                 string
+	CodeFrequency       bool
+	Releases            bool
+	OpenPRs             bool
+	NetworkRetries      int
+	LogLevel            string
+	LogFormat           string
+	Since               string
+	TokenFile           string
+	Participation       bool
+	Include             globList
+	Exclude             globList
+	DryRun              bool
+	ResumePending       bool
+	LifetimeCommits     bool
+	Serve               string
+	MinSize             string
+	MaxSize             string
+	Branches            bool
+	WorkflowRuns        bool
+	Config              string
+	EnrichCommits       bool
+	EnrichStats         bool
+	EnrichLanguages     bool
+	EnrichContributors  bool
+	EnrichCommunity     bool
+	Compare             string
+	RetryBase           time.Duration
+	RetryFactor         float64
+	RetryMax            time.Duration
+	StatsPolls          int
+
+	statsPushedWithinDays int
+	fieldList             []string
+	starBounds            []int
+	openIssueBounds       []int
+	mergePolicy           *mergePolicy
+	location              *time.Location
+	logLevel              slog.Level
+	since                 time.Time
+	minSizeKB             int
+	maxSizeKB             int
+}
+
+// maxTopContributors bounds --top-contributors; past 100 the list is paged,
+// within --contributor-pages.
+const maxTopContributors = 1000
+
+// optionalValue is a string flag that can also be given bare, like a
+// boolean: "--name" sets the implicit value, "--name=x" sets x.
+type optionalValue struct {
+	value    *string
+	implicit string
+}
+
+func (v optionalValue) String() string {
+	if v.value == nil {
+		return ""
+	}
+	return *v.value
+}
+
+func (v optionalValue) Set(s string) error {
+	switch s {
+	case "true":
+		*v.value = v.implicit
+	case "false":
+		*v.value = ""
+	default:
+		*v.value = s
+	}
+	return nil
+}
+
+func (v optionalValue) IsBoolFlag() bool { return true }
+
+// globList is a repeatable flag collecting repo name globs.
+type globList []string
+
+func (l *globList) String() string { return strings.Join(*l, ",") }
+
+func (l *globList) Set(s string) error {
+	if _, err := path.Match(s, ""); err != nil {
+		return fmt.Errorf("bad glob %q: %w", s, err)
+	}
+	*l = append(*l, s)
+	return nil
+}
+
+func parseOptions(args []string) (options, error) {
+	var o options
+
+	fs := flag.NewFlagSet("fetcher", flag.ContinueOnError)
+	fs.StringVar(&o.SchemaDiff, "schema-diff", "", "compare the record schema of an index file (e.g. repos_index.json) against the enriched schema and exit")
+	fs.BoolVar(&o.ReenrichArchived, "reenrich-archived", false, "re-fetch enrichment for archived repos instead of reusing the previous run's data")
+	fs.IntVar(&o.TopContributors, "top-contributors", 10, "number of top contributors to keep per repo (1-1000; above 100 takes several pages, see --contributor-pages)")
+	fs.BoolVar(&o.ForkCompare, "fork-compare", false, "for forks, record how far the default branch is behind/ahead of the upstream default branch")
+	fs.BoolVar(&o.StatsWarmupOrder, "stats-warmup-order", false, "enrich the largest repos first so GitHub has longest to compute their stats")
+	fs.BoolVar(&o.GitHubOutput, "github-output", false, "write headline numbers to the GITHUB_OUTPUT file when running in GitHub Actions")
+	fs.BoolVar(&o.IssueRatio, "issue-ratio", false, "compute each repo's closed/total issue ratio via the search API (rate limited to 30 searches/minute)")
+	fs.StringVar(&o.Where, "where", "", whereHelp)
+	fs.Float64Var(&o.MaxOutputMB, "max-output-mb", 100, "warn when the index JSON is larger than this many MB (0 disables)")
+	fs.Float64Var(&o.HardMaxOutputMB, "max-output-hard-mb", 0, "refuse to write an index JSON larger than this many MB (0 disables)")
+	fs.StringVar(&o.StarBuckets, "star-buckets", "1,10,100,1000", "comma-separated lower bounds of the summary's star_buckets ranges")
+	fs.StringVar(&o.OpenIssueBuckets, "open-issue-buckets", "1,5,20,100", "comma-separated lower bounds of the summary's open issues distribution")
+	fs.StringVar(&o.Annotations, "annotations", "", "JSON file mapping repo full names to key-value notes merged into each repo's annotations")
+	fs.BoolVar(&o.MergeSettings, "merge-settings", false, "fetch each repo's merge settings (allowed merge methods, delete branch on merge); needs push access")
+	fs.StringVar(&o.MergePolicy, "merge-policy", "", "expected merge settings, e.g. squash,delete-branch; repos that differ are listed in the summary (implies --merge-settings)")
+	fs.StringVar(&o.ReplayErrors, "replay-errors", "", "re-run only the failed calls listed in an errors file (e.g. ../repos_errors.jsonl) and merge them into the existing index")
+	fs.BoolVar(&o.ResumePending, "resume-pending", false, "re-fetch only the stats the previous run left pending (listed in "+pendingFile+") and merge them into the existing index")
+	fs.StringVar(&o.APIVersion, "api-version", defaultAPIVersion, "REST API version sent as X-GitHub-Api-Version (empty to send none)")
+	fs.StringVar(&o.StatsPushedWithin, "stats-only-if-pushed-within", "", "skip the 52-week stats call for repos not pushed to within this many days (e.g. 90 or 90d)")
+	fs.BoolVar(&o.Timings, "timings", false, "record how long each repo took to enrich and list the slowest in the summary")
+	fs.BoolVar(&o.GraphQLEngagement, "graphql-engagement", false, "take stars and real watcher counts from GraphQL (REST reports watchers equal to stars)")
+	fs.BoolVar(&o.Events, "events", false, "instead of a full run, poll your recent events and refresh only the last-commit info of repos pushed to since the last summary")
+	fs.StringVar(&o.Fields, "fields", "", "comma-separated outRepo JSON keys to keep in the index, e.g. name,stars,language,last_commit_at")
+	fs.StringVar(&o.Combined, "combined", "", "write the summary and the index together as one JSON object to this file instead of the two separate files")
+	fs.StringVar(&o.LocalRoot, "local-root", "", "directory holding local clones as owner/repo; their working-tree size is recorded as local_size_kb")
+	fs.BoolVar(&o.PRAuthors, "pr-authors", false, "list the distinct authors of each repo's open pull requests, split into internal and external (up to 300 PRs per repo)")
+	fs.StringVar(&o.Graph, "graph", "", "also write a repo-contributor graph (nodes and weighted edges, node-link JSON) to this file")
+	fs.StringVar(&o.EnvFile, "env-file", defaultEnvFile, "dotenv file to read GITHUB_TOKEN and other variables from")
+	fs.BoolVar(&o.EnvOverrides, "env-overrides", false, "let the --env-file values replace variables already set in the environment (by default the environment wins)")
+	fs.BoolVar(&o.SplitByOwner, "split-by-owner", false, "write one index_{owner}.json per owner login instead of the single index (the summary stays combined)")
+	fs.BoolVar(&o.CIDetect, "ci-detect", false, "detect each repo's CI providers (Actions, CircleCI, Travis, GitLab CI, ...) from its config files; one or two calls per repo")
+	fs.BoolVar(&o.Version, "version", false, "print the version, commit and build date, and exit")
+	fs.IntVar(&o.RepoRetries, "repo-retries", 0, "re-run a repo's whole enrichment up to this many times, with backoff, when a call fails with a network, rate-limit or server error")
+	fs.StringVar(&o.OutputDir, "output-dir", defaultOutputDir, "directory the index, summary and errors files are read from and written to; created if missing")
+	fs.BoolVar(&o.Branches, "branches", false, "count each repo's branches and check whether its default branch is protected (two more calls per repo; protection needs admin access)")
+	fs.BoolVar(&o.WorkflowRuns, "workflow-runs", false, "record each repo's Actions workflow run count and the conclusion of the latest run, or no_actions when Actions is disabled (one more call per repo)")
+	fs.BoolVar(&o.WorkflowPermissions, "workflow-permissions", false, "fetch each repo's default GITHUB_TOKEN workflow permissions (read or write); needs admin access")
+	fs.BoolVar(&o.Hash, "hash", false, "add a content_hash (sha256 of the enrichment fields) to each repo, to spot unchanged repos between runs")
+	fs.IntVar(&o.MaxRetries, "max-retries", 3, "retry a request refused by a secondary rate limit (429, or 403 with Retry-After) up to this many times")
+	fs.DurationVar(&o.MaxRetryWait, "max-retry-wait", time.Minute, "longest wait before one of those retries, whatever Retry-After says")
+	fs.Var(optionalValue{&o.Stdout, "index"}, "stdout", "write the index JSON (or the summary, with --stdout=summary) to stdout instead of files; progress goes to stderr")
+	fs.IntVar(&o.ContributorPages, "contributor-pages", 3, "most pages of contributors fetched per repo for --top-contributors above 100; the total count is exact regardless")
+	fs.StringVar(&o.Timezone, "timezone", "UTC", "zone for output timestamps: UTC, Local, or an IANA name such as Europe/Paris")
+	fs.StringVar(&o.API, "api", "rest", "how to fetch per-repo data: rest, or graphql to prefetch languages and the last commit for 50 repos per query; the repo listing and the other steps stay on REST")
+	fs.BoolVar(&o.AllBranches, "all-branches", false, "also record the newest commit across branches as last_commit_at_any_branch; one call per distinct branch tip")
+	fs.IntVar(&o.MaxBranches, "max-branches", 20, "most branches checked per repo by --all-branches")
+	fs.StringVar(&o.BaseURL, "base-url", envOr("GITHUB_API_URL", defaultBaseURL), "REST API root, e.g. https://ghe.example.com/api/v3 for Enterprise Server (default from GITHUB_API_URL)")
+	fs.IntVar(&o.Workers, "workers", defaultEnrichWorkers, fmt.Sprintf("most repositories enriched at once (1-%d); fewer are used as the rate limit runs low", maxEnrichWorkers))
+	fs.DurationVar(&o.RequestDelay, "request-delay", 100*time.Millisecond, "pause a worker takes between repositories; it grows as the rate limit runs low")
+	fs.StringVar(&o.Format, "format", "json", "output format: json, csv (writes "+indexCSVFile+" instead of the JSON index), both, jsonl (streams "+indexJSONLFile+" while enriching, instead of the JSON index), markdown (also writes "+reportFile+") or prometheus (also writes "+metricsFile+", gauges for a textfile collector)")
+	fs.StringVar(&o.Compare, "compare", "", "diff this run against a previous JSON index (e.g. an older "+indexFile+") and write "+changesFile+": added, removed and newly archived repos, and changes in stars, forks, open issues, last commit and latest release")
+	fs.StringVar(&o.SQLite, "sqlite", "", "also write the index and summary into a SQLite database at this path (replaced if it exists)")
+	fs.StringVar(&o.TokenFile, "token-file", "", "read the token from this file instead of GITHUB_TOKEN (GITHUB_TOKEN_FILE also works); without either, the GitHub CLI's token (gh auth token) is tried")
+	fs.StringVar(&o.User, "user", "", "report on this user's public repositories instead of the ones your token can access")
+	fs.StringVar(&o.Org, "org", "", "report on this organization's repositories (the public ones, plus any your token can see)")
+	fs.BoolVar(&o.EnrichCommits, "enrich-commits", true, "fetch each repo's last commit; --enrich-commits=false skips the call")
+	fs.BoolVar(&o.EnrichStats, "enrich-stats", true, "fetch each repo's 52-week commit activity; --enrich-stats=false skips the slowest call of the run, polled through 202s")
+	fs.BoolVar(&o.EnrichLanguages, "enrich-languages", true, "fetch each repo's language breakdown; --enrich-languages=false skips the call")
+	fs.BoolVar(&o.EnrichContributors, "enrich-contributors", true, "fetch each repo's top contributors; --enrich-contributors=false skips the call(s)")
+	fs.BoolVar(&o.EnrichCommunity, "enrich-community", true, "fetch each repo's community profile (health percentage, code of conduct, contributing guide); --enrich-community=false skips the call")
+	fs.BoolVar(&o.CodeFrequency, "code-frequency", false, "also fetch the weekly additions and deletions of the last 52 weeks (one more stats call per repo)")
+	fs.BoolVar(&o.Participation, "participation", false, "also fetch 52 weeks of owner vs all commit counts, for the summary's community_commit_ratio (one more stats call per repo)")
+	fs.BoolVar(&o.LifetimeCommits, "lifetime-commits", false, "also sum /stats/contributors into lifetime_commits, the all-time count next to the 52-week total_commits (one more stats call per repo)")
+	fs.BoolVar(&o.Releases, "releases", false, "also record each repo's latest release tag, name and date (one more call per repo)")
+	fs.BoolVar(&o.OpenPRs, "open-prs", false, "also count open pull requests, and record open_issues_only without them (one more call per repo)")
+	fs.StringVar(&o.Since, "since", "", "only enrich repos pushed to at or after this time (RFC3339, or a date like 2024-01-31); repos with no push date, and archived repos reused from the previous index, are kept")
+	fs.Var(&o.Include, "include", "only enrich repos whose owner/name matches this glob, e.g. myorg/service-* (repeatable; a glob without a / matches the name alone)")
+	fs.Var(&o.Exclude, "exclude", "skip repos whose owner/name matches this glob, e.g. *-archive (repeatable; wins over --include)")
+	fs.StringVar(&o.MinSize, "min-size", "", "only enrich repos at least this big, e.g. 500KB or 1.5 GB (KB when no unit is given)")
+	fs.StringVar(&o.MaxSize, "max-size", "", "only enrich repos at most this big, e.g. 2GB (KB when no unit is given)")
+	fs.BoolVar(&o.IncludeForks, "include-forks", true, "include forks; --include-forks=false drops them before enrichment")
+	fs.BoolVar(&o.IncludeArchived, "include-archived", true, "include archived repositories; --include-archived=false drops them before enrichment")
+	fs.BoolVar(&o.IncludePrivate, "include-private", true, "include private repositories; --include-private=false drops them before enrichment")
+	fs.DurationVar(&o.RetryBase, "retry-base", 500*time.Millisecond, "wait before the first retry of a failed request, stats endpoint still computing (202) or repo (--repo-retries); each later wait grows by --retry-factor, and a random part of each is taken so workers don't retry in lockstep")
+	fs.Float64Var(&o.RetryFactor, "retry-factor", 2, "growth of the retry waits from one retry to the next (at least 1)")
+	fs.DurationVar(&o.RetryMax, "retry-max", 30*time.Second, "longest of those waits")
+	fs.IntVar(&o.NetworkRetries, "network-retries", 3, "retries of a request failing with a transient network error (timeout, reset connection, truncated response), with waits following --retry-base")
+	fs.IntVar(&o.StatsPolls, "stats-polls", 4, "polls of a statistics endpoint after it answers 202 (GitHub still computing), with waits following --retry-base, before the repo is left stats-pending")
+	fs.StringVar(&o.LogLevel, "log-level", "info", "least severe progress messages shown: debug, info, warn or error")
+	fs.StringVar(&o.LogFormat, "log-format", "human", "progress output format: human (emoji, one message per line), text (logfmt) or json")
+	fs.BoolVar(&o.DryRun, "dry-run", false, "list the repos that would be enriched, after the filters, with an estimate of the requests; nothing is enriched or written")
+	fs.StringVar(&o.Serve, "serve", "", "instead of writing files, enrich once and serve the results as JSON on this address (e.g. :8080): /repos, /repos/{owner}/{name}, /summary, and POST /refresh to re-run; --timeout then bounds each run")
+	fs.StringVar(&o.Config, "config", "", "read options from this YAML or JSON file, keyed by flag name (see Config in config.go); flags given here win over it")
+	fs.DurationVar(&o.Timeout, "timeout", 0, "overall deadline for the run, e.g. 30m; when it passes, like on Ctrl-C, enrichment stops and what was collected is written (0 means none)")
+	fs.Var(optionalValue{&o.Cache, defaultCacheDir}, "cache", "keep responses with their ETags in "+defaultCacheDir+" (or --cache=dir) and send conditional requests, so unchanged data costs no quota")
+	fs.Var(optionalValue{&o.ProgressJSON, "-"}, "progress-json", "emit progress as JSON lines to stdout, or to the given file/pipe with --progress-json=path; disables the human output")
+
+	if err := fs.Parse(args); err != nil {
+		return o, err
+	}
+	if o.Config != "" {
+		cfg, err := loadConfig(o.Config)
+		if err == nil {
+			err = cfg.apply(fs)
+		}
+		if err != nil {
+			err = fmt.Errorf("--config: %w", err)
+			fmt.Fprintln(fs.Output(), err)
+			return o, err
+		}
+	}
+
+	if err := o.validate(); err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		return o, err
+	}
+	return o, nil
+}
+
+// envOr returns the environment variable, or def when it's unset or empty.
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// validate rejects inconsistent options and clamps out-of-range numbers.
+func (o *options) validate() error {
+	if o.Stdout != "" && o.Stdout != "index" && o.Stdout != "summary" {
+		return fmt.Errorf("--stdout must be index or summary, got %q", o.Stdout)
+	}
+	if o.Stdout != "" && o.ProgressJSON == "-" {
+		return fmt.Errorf("--stdout and --progress-json can't both write to stdout; give --progress-json a path")
+	}
+	if o.Stdout != "" && o.Combined != "" {
+		return fmt.Errorf("--stdout and --combined are mutually exclusive")
+	}
+	if o.SplitByOwner && (o.Stdout != "" || o.Combined != "") {
+		return fmt.Errorf("--split-by-owner can't be combined with --stdout or --combined")
+	}
+
+	if o.User != "" && o.Org != "" {
+		return fmt.Errorf("--user and --org are mutually exclusive")
+	}
+	if o.Events && (o.User != "" || o.Org != "") {
+		return fmt.Errorf("--events follows your own activity, so it can't be combined with --user or --org")
+	}
+
+	if o.ResumePending && o.ReplayErrors != "" {
+		return fmt.Errorf("--resume-pending and --replay-errors are mutually exclusive")
+	}
+	if o.Serve != "" && (o.Events || o.DryRun || o.ReplayErrors != "" || o.ResumePending) {
+		return fmt.Errorf("--serve can't be combined with --events, --dry-run, --replay-errors or --resume-pending")
+	}
+	if o.Serve != "" && (o.Stdout != "" || o.Combined != "" || o.SplitByOwner || o.Format != "json") {
+		return fmt.Errorf("--serve serves the results instead of writing them, so it can't be combined with --stdout, --combined, --split-by-owner or --format")
+	}
+	if o.DryRun && o.Events {
+		return fmt.Errorf("--dry-run previews the repository run, so it can't be combined with --events")
+	}
+
+	if err := o.logLevel.UnmarshalText([]byte(o.LogLevel)); err != nil {
+		return fmt.Errorf("--log-level must be debug, info, warn or error, got %q", o.LogLevel)
+	}
+	if o.LogFormat != "human" && o.LogFormat != "text" && o.LogFormat != "json" {
+		return fmt.Errorf("--log-format must be human, text or json, got %q", o.LogFormat)
+	}
+
+	if o.Since != "" {
+		t, err := time.Parse(time.RFC3339, o.Since)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, o.Since); err != nil {
+				return fmt.Errorf("--since must be an RFC3339 time or a date like 2024-01-31, got %q", o.Since)
+			}
+		}
+		o.since = t
+	}
+
+	if o.MinSize != "" {
+		kb, err := parseHumanSize(o.MinSize)
+		if err != nil {
+			return fmt.Errorf("--min-size: %v", err)
+		}
+		o.minSizeKB = kb
+	}
+	if o.MaxSize != "" {
+		kb, err := parseHumanSize(o.MaxSize)
+		if err != nil {
+			return fmt.Errorf("--max-size: %v", err)
+		}
+		o.maxSizeKB = kb
+		if o.minSizeKB > kb {
+			return fmt.Errorf("--min-size is larger than --max-size")
+		}
+	}
+
+	switch o.Format {
+	case "json":
+	case "markdown", "prometheus":
+		if o.Stdout != "" {
+			return fmt.Errorf("--format=%s writes a file, so it can't be combined with --stdout", o.Format)
+		}
+	case "csv", "both", "jsonl":
+		if o.Stdout != "" || o.Combined != "" || o.SplitByOwner {
+			return fmt.Errorf("--format=%s can't be combined with --stdout, --combined or --split-by-owner", o.Format)
+		}
+	default:
+		return fmt.Errorf("--format must be json, csv, both, jsonl, markdown or prometheus, got %q", o.Format)
+	}
+
+	if u, err := url.Parse(o.BaseURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("--base-url must be an http(s) URL such as https://ghe.example.com/api/v3, got %q", o.BaseURL)
+	}
+
+	if o.API != "rest" && o.API != "graphql" {
+		return fmt.Errorf("--api must be rest or graphql, got %q", o.API)
+	}
+
+	loc, err := time.LoadLocation(o.Timezone)
+	if err != nil {
+		return fmt.Errorf("--timezone: unknown zone %q (want UTC, Local or an IANA name like Europe/Paris)", o.Timezone)
+	}
+	o.location = loc
+
+	bounds, err := parseBucketBounds(o.StarBuckets)
+	if err != nil {
+		return fmt.Errorf("--star-buckets: %w", err)
+	}
+	o.starBounds = bounds
+
+	if o.openIssueBounds, err = parseBucketBounds(o.OpenIssueBuckets); err != nil {
+		return fmt.Errorf("--open-issue-buckets: %w", err)
+	}
+
+	if o.MergePolicy != "" {
+		p, err := parseMergePolicy(o.MergePolicy)
+		if err != nil {
+			return fmt.Errorf("--merge-policy: %w", err)
+		}
+		o.mergePolicy = &p
+		o.MergeSettings = true
+	}
+
+	if o.StatsPushedWithin != "" {
+		days, err := strconv.Atoi(strings.TrimSuffix(o.StatsPushedWithin, "d"))
+		if err != nil || days < 1 {
+			return fmt.Errorf("--stats-only-if-pushed-within: want a positive number of days, got %q", o.StatsPushedWithin)
+		}
+		o.statsPushedWithinDays = days
+	}
+
+	if o.Fields != "" {
+		fields, err := parseFieldList(o.Fields)
+		if err != nil {
+			return fmt.Errorf("--fields: %w", err)
+		}
+		o.fieldList = fields
+	}
+
+	if o.MaxRetries < 0 {
+		return fmt.Errorf("--max-retries can't be negative, got %d", o.MaxRetries)
+	}
+	if o.MaxRetryWait < 0 {
+		return fmt.Errorf("--max-retry-wait can't be negative, got %s", o.MaxRetryWait)
+	}
+	if o.RetryBase <= 0 || o.RetryMax < o.RetryBase {
+		return fmt.Errorf("--retry-base must be positive and at most --retry-max, got %s and %s", o.RetryBase, o.RetryMax)
+	}
+	if o.RetryFactor < 1 {
+		return fmt.Errorf("--retry-factor must be at least 1, got %g", o.RetryFactor)
+	}
+	if o.NetworkRetries < 0 {
+		return fmt.Errorf("--network-retries can't be negative, got %d", o.NetworkRetries)
+	}
+	if o.StatsPolls < 0 {
+		return fmt.Errorf("--stats-polls can't be negative, got %d", o.StatsPolls)
+	}
+	if o.RequestDelay < 0 {
+		return fmt.Errorf("--request-delay can't be negative, got %s", o.RequestDelay)
+	}
+	if o.Timeout < 0 {
+		return fmt.Errorf("--timeout can't be negative, got %s", o.Timeout)
+	}
+
+	if o.RepoRetries < 0 {
+		return fmt.Errorf("--repo-retries can't be negative, got %d", o.RepoRetries)
+	}
+
+	if o.MaxBranches < 1 {
+		return fmt.Errorf("--max-branches must be at least 1, got %d", o.MaxBranches)
+	}
+
+	if o.ContributorPages < 1 {
+		return fmt.Errorf("--contributor-pages must be at least 1, got %d", o.ContributorPages)
+	}
+
+	if o.TopContributors < 1 || o.TopContributors > maxTopContributors {
+		clamped := min(max(o.TopContributors, 1), maxTopContributors)
+		fmt.Fprintf(os.Stderr, "--top-contributors=%d is out of range, using %d\n", o.TopContributors, clamped)
+		o.TopContributors = clamped
+	}
+
+	if o.Workers < 1 {
+		return fmt.Errorf("--workers must be at least 1, got %d", o.Workers)
+	}
+	if o.Workers > maxEnrichWorkers {
+		fmt.Fprintf(os.Stderr, "--workers=%d is more than GitHub tolerates, using %d\n", o.Workers, maxEnrichWorkers)
+		o.Workers = maxEnrichWorkers
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ownerStats is one owner's line of the summary's per-owner section.
+type ownerStats struct {
+	Repos   int `json:"repos"`
+	Stars   int `json:"stars"`
+	Forks   int `json:"forks"`
+	SizeKB  int `json:"size_kb"`
+	Commits int `json:"commits"`
+}
+
+// partitionByOwner splits the index by owner login, keeping each owner's
+// repos in index order.
+func partitionByOwner(out []outRepo) map[string][]outRepo {
+	parts := map[string][]outRepo{}
+	for _, r := range out {
+		parts[r.OwnerLogin] = append(parts[r.OwnerLogin], r)
+	}
+	return parts
+}
+
+// ownerIndexPath is where --split-by-owner writes an owner's index, next to
+// where the single index would go.
+func ownerIndexPath(owner string) string {
+	return filepath.Join(filepath.Dir(indexPath), "index_"+owner+".json")
+}
+
+// writeOwnerIndexes writes one index per owner and returns the paths
+// written. fields, if set, trims each record as --fields does.
+func writeOwnerIndexes(out []outRepo, fields []string) ([]string, error) {
+	var paths []string
+	for owner, repos := range partitionByOwner(out) {
+		var v any = repos
+		if fields != nil {
+			projected, err := projectFields(repos, fields)
+			if err != nil {
+				return paths, err
+			}
+			v = projected
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return paths, err
+		}
+		path := ownerIndexPath(owner)
+		if err := writeFileAtomic(path, data); err != nil {
+			return paths, fmt.Errorf("%s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// writeFileAtomic writes data to a temporary file beside path and renames
+// it into place, so readers never see a half-written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
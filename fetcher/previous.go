@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"sort"
+)
+
+// loadPreviousIndex reads the index written by an earlier run. A missing
+// file isn't an error: it just means there is nothing to carry over.
+func loadPreviousIndex(path string) ([]outRepo, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var prev []outRepo
+	if err := json.Unmarshal(data, &prev); err != nil {
+		return nil, err
+	}
+	return prev, nil
+}
+
+// pendingFromIndex returns the full names of repos whose stats were still
+// being computed by GitHub when the index was written.
+func pendingFromIndex(prev []outRepo) map[string]bool {
+	pending := map[string]bool{}
+	for _, r := range prev {
+		if r.StatsCachePending {
+			pending[r.FullName] = true
+		}
+	}
+	return pending
+}
+
+// enrichmentOrder returns the indexes of out in the order they should be
+// enriched: repos left pending by the previous run go first, since GitHub
+// has had time to warm their stats. With bySize, the rest go largest
+// first so the slowest stats computations get kicked off earliest;
+// otherwise they keep their listing order.
+func enrichmentOrder(out []outRepo, pending map[string]bool, bySize bool) []int {
+	order := make([]int, len(out))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ra, rb := out[order[a]], out[order[b]]
+		pa, pb := pending[ra.FullName], pending[rb.FullName]
+		if pa != pb {
+			return pa
+		}
+		return bySize && ra.SizeKB > rb.SizeKB
+	})
+	return order
+}
+
+// reusableArchived returns the previous records whose enrichment can be
+// carried over, by full name: archived ones where every step opts enables
+// ran to completion, none failed or left pending. A record without
+// enriched_steps (an older or --fields index) is never reused.
+func reusableArchived(prev []outRepo, opts options) map[string]outRepo {
+	reusable := map[string]outRepo{}
+	for _, p := range prev {
+		if p.Archived && !p.StatsCachePending && len(p.EnrichmentErrors) == 0 && coversSteps(&p, opts) {
+			reusable[p.FullName] = p
+		}
+	}
+	return reusable
+}
+
+// coversSteps reports whether r's enriched steps include every step opts
+// enables for it.
+func coversSteps(r *outRepo, opts options) bool {
+	for _, step := range enrichSteps {
+		if step.enabled(opts, r) && !slices.Contains(r.EnrichedSteps, step.name) {
+			return false
+		}
+	}
+	return true
+}
+
+// carryOverArchived copies the previous run's enrichment onto archived repos
+// and returns order without them. An archived repo is read-only, so what
+// was fetched last time is still accurate and fetching it again is wasted
+// quota. Repos the previous run didn't fetch completely are fetched again.
+func carryOverArchived(out []outRepo, prev []outRepo, order []int, opts options) []int {
+	reusable := reusableArchived(prev, opts)
+
+	remaining := make([]int, 0, len(order))
+	reused := 0
+	for _, i := range order {
+		p, ok := reusable[out[i].FullName]
+		if out[i].Archived && ok {
+			out[i].repoEnrichment = p.repoEnrichment
+			reused++
+			continue
+		}
+		remaining = append(remaining, i)
+	}
+	if reused > 0 {
+		logInfof("♻️  Reused previous enrichment for %d archived repositories", reused)
+	}
+	return remaining
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// enrichedWith is an archived record the previous run fetched with args.
+func enrichedWith(t *testing.T, full string, args ...string) outRepo {
+	t.Helper()
+	opts, err := parseOptions(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := outRepo{FullName: full, Archived: true}
+	r.TotalCommits = 7
+	for _, step := range enrichSteps {
+		if step.enabled(opts, &r) {
+			r.EnrichedSteps = append(r.EnrichedSteps, step.name)
+		}
+	}
+	return r
+}
+
+func TestCarryOverArchived(t *testing.T) {
+	done := enrichedWith(t, "o/done")
+	failed := enrichedWith(t, "o/failed")
+	failed.EnrichmentErrors = map[string]string{"languages": "languages error 502"}
+	pending := enrichedWith(t, "o/pending")
+	pending.StatsCachePending = true
+	noStats := enrichedWith(t, "o/nostats", "--enrich-stats=false")
+	// A --fields index has no enriched_steps
+	projected := outRepo{FullName: "o/projected", Archived: true}
+	prev := []outRepo{done, failed, pending, noStats, projected}
+
+	tests := []struct {
+		name  string
+		args  []string
+		reuse []string
+	}{
+		{"default run", nil, []string{"o/done"}},
+		{"stats off now too", []string{"--enrich-stats=false"}, []string{"o/done", "o/nostats"}},
+		{"opt-in step the previous run didn't do", []string{"--releases"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discardLogs(t)
+			opts, err := parseOptions(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out []outRepo
+			var order []int
+			for i, p := range prev {
+				out = append(out, outRepo{FullName: p.FullName, Archived: true})
+				order = append(order, i)
+			}
+			out = append(out, outRepo{FullName: "o/live"})
+			order = append(order, len(prev))
+
+			remaining := carryOverArchived(out, prev, order, opts)
+			var reused []string
+			for _, i := range order {
+				if !slices.Contains(remaining, i) {
+					reused = append(reused, out[i].FullName)
+					if out[i].TotalCommits != 7 {
+						t.Errorf("%s: total commits %d, want the carried-over 7", out[i].FullName, out[i].TotalCommits)
+					}
+				} else if out[i].TotalCommits != 0 {
+					t.Errorf("%s: carried over though it's fetched again", out[i].FullName)
+				}
+			}
+			if !slices.Equal(reused, tt.reuse) {
+				t.Errorf("reused %v, want %v", reused, tt.reuse)
+			}
+		})
+	}
+}
+
+func TestFilterPushedSinceKeepsReusableArchived(t *testing.T) {
+	since := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	repos := []ghRepo{
+		{FullName: "o/new", PushedAt: "2024-07-01T00:00:00Z"},
+		{FullName: "o/stale", PushedAt: "2020-01-01T00:00:00Z"},
+		{FullName: "o/archived", PushedAt: "2020-01-01T00:00:00Z", Archived: true},
+		{FullName: "o/unarchived", PushedAt: "2020-01-01T00:00:00Z"},
+		{FullName: "o/projected", PushedAt: "2020-01-01T00:00:00Z", Archived: true},
+	}
+	opts, err := parseOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := reusableArchived([]outRepo{
+		enrichedWith(t, "o/archived"),
+		enrichedWith(t, "o/unarchived"),
+		{FullName: "o/projected", Archived: true},
+	}, opts)
+
+	var got []string
+	for _, r := range filterPushedSince(repos, since, keep) {
+		got = append(got, r.FullName)
+	}
+	if want := []string{"o/new", "o/archived"}; !slices.Equal(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+}
+
+func TestProjectionNeverCarriesEnrichedSteps(t *testing.T) {
+	if _, err := parseFieldList("full_name,enriched_steps"); err == nil {
+		t.Error("--fields accepted enriched_steps")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressEvent is one line of the --progress-json stream.
+type progressEvent struct {
+	Event string `json:"event"`
+	Time  string `json:"time"`
+	Phase string `json:"phase,omitempty"`
+	Repo  string `json:"repo,omitempty"`
+	Index int    `json:"index,omitempty"`
+	Total int    `json:"total,omitempty"`
+}
+
+// progressEmitter writes lifecycle events as JSON lines for wrappers that
+// render their own progress view. A nil emitter drops everything, so call
+// sites don't need to check whether the mode is on.
+type progressEmitter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	w   io.WriteCloser
+}
+
+// newProgressEmitter opens dest for event output; "-" means stdout.
+// Anything else (a file or a named pipe) is opened for appending.
+func newProgressEmitter(dest string) (*progressEmitter, error) {
+	if dest == "" {
+		return nil, nil
+	}
+
+	var w io.WriteCloser = os.Stdout
+	if dest != "-" {
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	}
+	return &progressEmitter{enc: json.NewEncoder(w), w: w}, nil
+}
+
+func (p *progressEmitter) emit(ev progressEvent) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ev.Time = time.Now().UTC().Format(time.RFC3339)
+	_ = p.enc.Encode(ev)
+}
+
+func (p *progressEmitter) phase(name string) {
+	p.emit(progressEvent{Event: "phase-change", Phase: name})
+}
+
+func (p *progressEmitter) Close() error {
+	if p == nil || p.w == os.Stdout {
+		return nil
+	}
+	return p.w.Close()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// promRepoGauges are the per-repo gauges of the metrics file, each labelled
+// with the repo's full name.
+var promRepoGauges = []struct {
+	name, help string
+	value      func(r outRepo) int
+}{
+	{"gitlore_repo_stars", "Stargazers of the repository.", func(r outRepo) int { return r.Stars }},
+	{"gitlore_repo_forks", "Forks of the repository.", func(r outRepo) int { return r.Forks }},
+	{"gitlore_repo_watchers", "Watchers of the repository.", func(r outRepo) int { return r.Watchers }},
+	{"gitlore_repo_open_issues", "Open issues of the repository, pull requests included.", func(r outRepo) int { return r.OpenIssues }},
+	{"gitlore_repo_size_kb", "Size of the repository in KB.", func(r outRepo) int { return r.SizeKB }},
+	{"gitlore_repo_commits_52w", "Commits to the default branch over the last 52 weeks.", func(r outRepo) int { return r.TotalCommits }},
+	{"gitlore_repo_contributors", "Contributors to the repository.", func(r outRepo) int { return r.ContributorCount }},
+}
+
+// promMetrics renders the run in the Prometheus text exposition format:
+// the per-repo gauges, then portfolio totals from the summary. The result
+// suits node_exporter's textfile collector or any static exporter.
+func promMetrics(sum summary, out []outRepo) []byte {
+	var b bytes.Buffer
+	for _, g := range promRepoGauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, r := range out {
+			fmt.Fprintf(&b, "%s{repo=\"%s\"} %d\n", g.name, promLabel(r.FullName), g.value(r))
+		}
+	}
+
+	totals := []struct {
+		name, help string
+		value      int
+	}{
+		{"gitlore_repos", "Repositories in the portfolio.", sum.RepoCounts.Total},
+		{"gitlore_total_stars", "Stargazers across the portfolio.", sum.Engagement.TotalStars},
+		{"gitlore_total_forks", "Forks across the portfolio.", sum.Engagement.TotalForks},
+		{"gitlore_total_watchers", "Watchers across the portfolio.", sum.Engagement.TotalWatchers},
+		{"gitlore_total_commits_52w", "Commits over the last 52 weeks across the portfolio.", sum.Engagement.TotalCommits},
+		{"gitlore_total_size_kb", "Size of the portfolio in KB.", sum.Size.TotalKB},
+		{"gitlore_repos_stats_pending", "Repositories whose statistics GitHub was still computing.", sum.Enrichment.ReposStatsPending},
+	}
+	for _, t := range totals {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", t.name, t.help, t.name, t.name, t.value)
+	}
+	return b.Bytes()
+}
+
+// promLabelEscaper escapes a label value as the text format requires.
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promLabel(v string) string { return promLabelEscaper.Replace(v) }
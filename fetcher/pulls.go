@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// pullsMaxPages caps the open-PR listing per repo at 300 pull requests;
+// busier repos get the authors of their 300 most recent ones.
+const pullsMaxPages = 3
+
+// internalAssociations are the author_association values of people with a
+// standing in the repo; any other author counts as an outside contributor.
+var internalAssociations = map[string]bool{
+	"OWNER":        true,
+	"MEMBER":       true,
+	"COLLABORATOR": true,
+}
+
+// fetchOpenPRAuthors returns the distinct authors of a repo's open pull
+// requests, sorted, and the subset of them from outside the repo.
+func fetchOpenPRAuthors(ctx context.Context, client *http.Client, token, fullName string) (authors, external []string, err error) {
+	internal := map[string]bool{}
+	seen := map[string]bool{}
+	for page := 1; page <= pullsMaxPages; page++ {
+		url := apiURL(fmt.Sprintf("/repos/%s/pulls?state=open&per_page=100&page=%d", fullName, page))
+		status, body, err := doGET(ctx, client, url, token)
+		if err != nil {
+			return nil, nil, err
+		}
+		if status < 200 || status >= 300 {
+			return nil, nil, fmt.Errorf("pulls error %d", status)
+		}
+
+		var pulls []struct {
+			User struct {
+				Login string `json:"login"`
+			} `json:"user"`
+			AuthorAssociation string `json:"author_association"`
+		}
+		if err := json.Unmarshal(body, &pulls); err != nil {
+			return nil, nil, err
+		}
+
+		for _, p := range pulls {
+			login := p.User.Login
+			if login == "" {
+				continue
+			}
+			seen[login] = true
+			if internalAssociations[p.AuthorAssociation] {
+				internal[login] = true
+			}
+		}
+		if len(pulls) < 100 {
+			break
+		}
+	}
+
+	for login := range seen {
+		authors = append(authors, login)
+		if !internal[login] {
+			external = append(external, login)
+		}
+	}
+	sort.Strings(authors)
+	sort.Strings(external)
+	return authors, external, nil
+}
+
+// fetchOpenPRCount counts a repo's open pull requests from the Link header
+// of a one-item page. The pulls endpoint answers even when issues are
+// disabled.
+func fetchOpenPRCount(ctx context.Context, client *http.Client, token, fullName string) (int, error) {
+	return countViaLink(ctx, client, token, apiURL(fmt.Sprintf("/repos/%s/pulls?state=open&per_page=1", fullName)))
+}
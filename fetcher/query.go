@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// queryUsage documents the query subcommand.
+const queryUsage = `usage: fetcher query INDEX.json [EXPR]
+
+EXPR is a jq-like pipeline over the index's repos, stages separated by |:
+  .[]            every repo (the default; may be omitted)
+  select(COND)   keep repos matching COND, in --where syntax: select(stars>10 && fork==false)
+  .a,.b          print just these fields, one repo per line, tab-separated
+  length         print the number of repos
+Without a field stage each repo is printed as one line of JSON.
+Example: fetcher query ../repos_index_enriched.json '.[] | select(language==Go) | .full_name,.stars'`
+
+// runQuery evaluates a query over an index file for scripting without jq.
+func runQuery(args []string, w io.Writer) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("%s", queryUsage)
+	}
+	repos, err := loadPreviousIndex(args[0])
+	if err != nil {
+		return err
+	}
+	if repos == nil {
+		return fmt.Errorf("%s: no such file", args[0])
+	}
+
+	var fields []string
+	count := false
+	if len(args) == 2 {
+		stages := splitPipeline(args[1])
+		for n, stage := range stages {
+			last := n == len(stages)-1
+			switch {
+			case stage == ".[]" || stage == "." || stage == "":
+			case strings.HasPrefix(stage, "select(") && strings.HasSuffix(stage, ")"):
+				expr, err := parseWhere(stage[len("select(") : len(stage)-1])
+				if err != nil {
+					return err
+				}
+				if repos, err = filterWhere(repos, expr); err != nil {
+					return err
+				}
+			case stage == "length" && last:
+				count = true
+			case !strings.ContainsAny(stage, "() ") && last:
+				spec := strings.ReplaceAll(stage, ".", "")
+				if fields, err = parseFieldList(spec); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("query: can't handle stage %q (field lists and length must come last)\n\n%s", stage, queryUsage)
+			}
+		}
+	}
+
+	if count {
+		_, err := fmt.Fprintln(w, len(repos))
+		return err
+	}
+	for _, r := range repos {
+		line, err := queryLine(r, fields)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryLine renders one repo: the whole record as JSON, or the given
+// fields tab-separated with strings printed raw.
+func queryLine(r outRepo, fields []string) (string, error) {
+	if fields == nil {
+		data, err := json.Marshal(r)
+		return string(data), err
+	}
+	rec, err := toJSONMap(r)
+	if err != nil {
+		return "", err
+	}
+	vals := make([]string, len(fields))
+	for i, f := range fields {
+		if s, ok := rec[f].(string); ok {
+			vals[i] = s
+			continue
+		}
+		data, err := json.Marshal(rec[f])
+		if err != nil {
+			return "", err
+		}
+		vals[i] = string(data)
+	}
+	return strings.Join(vals, "\t"), nil
+}
+
+// splitPipeline splits a query on the | between stages, leaving alone the
+// || of a select condition and anything in quotes or parentheses.
+func splitPipeline(src string) []string {
+	var stages []string
+	depth, start := 0, 0
+	var quote rune
+	for i, r := range src {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == '|' && depth == 0:
+			stages = append(stages, strings.TrimSpace(src[start:i]))
+			start = i + 1
+		}
+	}
+	return append(stages, strings.TrimSpace(src[start:]))
+}
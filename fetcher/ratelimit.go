@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimit is a quota as GitHub reports it in the X-RateLimit-* headers.
+type rateLimit struct {
+	Resource  string // "core", "search", ...
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// parseRateLimit reads the X-RateLimit-* headers of a response. ok is false
+// when they're missing, e.g. on errors from a proxy.
+func parseRateLimit(h http.Header) (rl rateLimit, ok bool) {
+	remaining, err1 := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	limit, err2 := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	reset, err3 := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err1 != nil || err2 != nil || limit <= 0 {
+		return rateLimit{}, false
+	}
+	rl = rateLimit{Resource: h.Get("X-RateLimit-Resource"), Limit: limit, Remaining: remaining}
+	if rl.Resource == "" {
+		rl.Resource = "core"
+	}
+	if err3 == nil {
+		rl.Reset = time.Unix(reset, 0)
+	}
+	return rl, true
+}
+
+// quotas holds the last rateLimit seen per resource, shared by all workers.
+var quotas = struct {
+	mu       sync.Mutex
+	byName   map[string]rateLimit
+	announce map[string]time.Time // reset we last logged a wait for
+}{byName: map[string]rateLimit{}, announce: map[string]time.Time{}}
+
+func recordRateLimit(h http.Header) {
+	rl, ok := parseRateLimit(h)
+	if !ok {
+		return
+	}
+	quotas.mu.Lock()
+	quotas.byName[rl.Resource] = rl
+	quotas.mu.Unlock()
+}
+
+// currentRateLimit returns the last reported quota of a resource, for
+// callers that want to log how close they are to it.
+func currentRateLimit(resource string) (rateLimit, bool) {
+	quotas.mu.Lock()
+	defer quotas.mu.Unlock()
+	rl, ok := quotas.byName[resource]
+	return rl, ok
+}
+
+// resourceFor names the quota a REST URL counts against.
+func resourceFor(url string) string {
+	if strings.Contains(url, "/search/") {
+		return "search"
+	}
+	return "core"
+}
+
+// waitForQuota blocks while the resource's quota is used up, until the
+// reset time GitHub gave for it (plus a second of slack for clock skew).
+func waitForQuota(ctx context.Context, resource string) error {
+	quotas.mu.Lock()
+	rl, ok := quotas.byName[resource]
+	if !ok || rl.Remaining > 0 || rl.Reset.IsZero() {
+		quotas.mu.Unlock()
+		return nil
+	}
+	wait := time.Until(rl.Reset) + time.Second
+	if wait <= 0 {
+		quotas.mu.Unlock()
+		return nil
+	}
+	if !quotas.announce[resource].Equal(rl.Reset) {
+		quotas.announce[resource] = rl.Reset
+		logWarnf("⏸️  %s rate limit used up, waiting %s until it resets at %s",
+			resource, wait.Round(time.Second), rl.Reset.Format("15:04:05"))
+	}
+	quotas.mu.Unlock()
+	return sleepCtx(ctx, wait)
+}
+
+// sleepCtx sleeps for d, or until ctx is done, in which case it returns
+// ctx's error.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isRateLimited reports whether a response was refused for an exhausted
+// primary rate limit, as opposed to a permissions 403.
+func isRateLimited(status int, h http.Header) bool {
+	if status != 403 && status != 429 {
+		return false
+	}
+	rl, ok := parseRateLimit(h)
+	return ok && rl.Remaining == 0
+}
+
+// requestDelay is the least pause between repos, whatever the quota; set
+// from --request-delay.
+var requestDelay = 100 * time.Millisecond
+
+// quotaLevel is how much concurrency and delay the remaining quota allows.
+type quotaLevel struct {
+	minShare float64 // applies while remaining/limit is at least this
+	workers  int     // 0 means the pool's maximum
+	delay    time.Duration
+}
+
+var quotaLevels = []quotaLevel{
+	{0.5, 0, 0},
+	{0.2, 3, 250 * time.Millisecond},
+	{0.05, 2, 500 * time.Millisecond},
+	{0, 1, time.Second},
+}
+
+func currentQuotaLevel() (quotaLevel, int) {
+	rl, ok := currentRateLimit("core")
+	if !ok {
+		return quotaLevels[0], -1
+	}
+	share := float64(rl.Remaining) / float64(rl.Limit)
+	for _, l := range quotaLevels {
+		if share >= l.minShare {
+			return l, rl.Remaining
+		}
+	}
+	return quotaLevels[len(quotaLevels)-1], rl.Remaining
+}
+
+// adaptivePool is a semaphore whose size follows the remaining quota: all
+// max slots while it's healthy, fewer (with a longer pause between repos)
+// as it runs out.
+type adaptivePool struct {
+	max int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	active  int
+	allowed int
+}
+
+func newAdaptivePool(max int) *adaptivePool {
+	p := &adaptivePool{max: max, allowed: max}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// acquire blocks until a slot is free under the current quota level.
+func (p *adaptivePool) acquire() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		p.rescale()
+		if p.active < p.allowed {
+			p.active++
+			return
+		}
+		p.cond.Wait()
+	}
+}
+
+// release frees a slot and returns how long the worker should pause
+// before taking the next one.
+func (p *adaptivePool) release() time.Duration {
+	level, _ := currentQuotaLevel()
+	p.mu.Lock()
+	p.active--
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	return max(level.delay, requestDelay)
+}
+
+// rescale recomputes allowed from the quota; p.mu must be held.
+func (p *adaptivePool) rescale() {
+	level, remaining := currentQuotaLevel()
+	allowed := p.max
+	if level.workers > 0 && level.workers < p.max {
+		allowed = level.workers
+	}
+	if allowed != p.allowed {
+		logDebugf("  ⏳ %d API calls left, scaling to %d workers", remaining, allowed)
+		p.allowed = allowed
+	}
+}
+
+// maxRetries and maxRetryWait bound the secondary rate limit retries so a
+// misbehaving server can't hang the run; set from --max-retries and
+// --max-retry-wait.
+var (
+	maxRetries   = 3
+	maxRetryWait = time.Minute
+)
+
+// isSecondaryLimited reports whether a response was refused by a secondary
+// (abuse) rate limit: a 429, or a 403 that carries Retry-After or says so,
+// while the primary quota isn't exhausted.
+func isSecondaryLimited(status int, h http.Header, body []byte) bool {
+	if isRateLimited(status, h) {
+		return false
+	}
+	switch status {
+	case 429:
+		return true
+	case 403:
+		return h.Get("Retry-After") != "" || strings.Contains(strings.ToLower(string(body)), "secondary rate limit")
+	}
+	return false
+}
+
+// retryAfter is how long to wait before retry n: the server's Retry-After
+// when given, otherwise retryBackoff from ten times its base, since these
+// limits take longer to lift. Either way it's capped at maxRetryWait.
+func retryAfter(h http.Header, n int) time.Duration {
+	if secs, err := strconv.Atoi(h.Get("Retry-After")); err == nil && secs >= 0 {
+		return min(time.Duration(secs)*time.Second, maxRetryWait)
+	}
+	p := backoffPolicy{base: 10 * retryBackoff.base, factor: retryBackoff.factor, max: maxRetryWait}
+	return p.wait(n)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type latestRelease struct {
+	TagName     string `json:"tag_name"`
+	Name        string `json:"name"`
+	PublishedAt string `json:"published_at"`
+}
+
+// fetchLatestRelease returns the repo's latest published release (drafts
+// and prereleases aside). found is false when it has none.
+func fetchLatestRelease(ctx context.Context, client *http.Client, token, fullName string) (latestRelease, bool, error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/releases/latest", fullName))
+	status, body, err := doGET(ctx, client, url, token)
+	if err != nil {
+		return latestRelease{}, false, err
+	}
+	if status == 404 {
+		return latestRelease{}, false, nil
+	}
+	if status < 200 || status >= 300 {
+		return latestRelease{}, false, fmt.Errorf("latest release error %d", status)
+	}
+
+	var rel latestRelease
+	if err := json.Unmarshal(body, &rel); err != nil {
+		return latestRelease{}, false, err
+	}
+	return rel, true, nil
+}
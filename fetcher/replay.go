@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// writeErrorsFile writes failed enrichment steps as JSON Lines. It always
+// rewrites the file, so an empty file means nothing is left failing.
+func writeErrorsFile(path string, errs []enrichError) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range errs {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func loadErrorsFile(path string) ([]enrichError, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var errs []enrichError
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e enrichError
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		errs = append(errs, e)
+	}
+	return errs, sc.Err()
+}
+
+// runReplayErrors re-runs only the enrichment steps a previous run recorded
+// as failed, merges the results into the existing index, and rewrites the
+// index, summary and errors file to match.
+func runReplayErrors(ctx context.Context, client *http.Client, token string, opts options) error {
+	errs, err := loadErrorsFile(opts.ReplayErrors)
+	if err != nil {
+		return err
+	}
+	out, err := loadPreviousIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return fmt.Errorf("no existing index at %s to merge into", indexPath)
+	}
+
+	byRepo := map[string]map[string]bool{}
+	for _, e := range errs {
+		if byRepo[e.FullName] == nil {
+			byRepo[e.FullName] = map[string]bool{}
+		}
+		byRepo[e.FullName][e.Endpoint] = true
+	}
+	logInfof("🔁 Replaying %d failed calls across %d repositories...", len(errs), len(byRepo))
+
+	var still []enrichError
+	inIndex := map[string]bool{}
+	for i := range out {
+		only, ok := byRepo[out[i].FullName]
+		if !ok {
+			continue
+		}
+		inIndex[out[i].FullName] = true
+		still = append(still, enrichRepo(ctx, client, token, opts, &out[i], only)...)
+		sleepCtx(ctx, requestDelay)
+	}
+	replayed := len(errs)
+
+	// Repos renamed, deleted or filtered out of the index since can't be
+	// replayed into it; keep their entries rather than count them recovered
+	var skipped []enrichError
+	for _, e := range errs {
+		if !inIndex[e.FullName] {
+			skipped = append(skipped, e)
+		}
+	}
+	if len(skipped) > 0 {
+		logWarnf("⚠️  Skipped %d calls of repositories no longer in %s; they stay in %s", len(skipped), indexPath, opts.ReplayErrors)
+		replayed -= len(skipped)
+	}
+
+	if err := writeMergedIndex(out, opts); err != nil {
+		return err
+	}
+	if err := writeErrorsFile(opts.ReplayErrors, append(still, skipped...)); err != nil {
+		return err
+	}
+
+	logInfof("✓ Recovered %d of %d replayed calls; %d still failing", replayed-len(still), replayed, len(still))
+	return nil
+}
+
+// statsPendingOf lists the stats steps left pending in out, in the errors
+// file format, for the pending file.
+func statsPendingOf(out []outRepo) []enrichError {
+	var pending []enrichError
+	for _, r := range out {
+		for _, step := range enrichSteps {
+			if r.statsPending[step.name] {
+				pending = append(pending, enrichError{FullName: r.FullName, Endpoint: step.name, Error: "statistics still being computed (202)"})
+			}
+		}
+	}
+	return pending
+}
+
+// runResumePending re-fetches just the stats that the pending file lists as
+// still being computed when the previous run ended, merges them into the
+// existing index, and rewrites the index, summary and pending file. Steps
+// that fail stay listed, since their data is still missing.
+func runResumePending(ctx context.Context, client *http.Client, token string, opts options) error {
+	pending, err := loadErrorsFile(pendingPath)
+	if err != nil {
+		return err
+	}
+	out, err := loadPreviousIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return fmt.Errorf("no existing index at %s to merge into", indexPath)
+	}
+
+	byRepo := map[string]map[string]bool{}
+	for _, p := range pending {
+		if byRepo[p.FullName] == nil {
+			byRepo[p.FullName] = map[string]bool{}
+		}
+		byRepo[p.FullName][p.Endpoint] = true
+	}
+	logInfof("⏳ Resuming %d pending stats across %d repositories...", len(pending), len(byRepo))
+
+	var failed []enrichError
+	for i := range out {
+		only, ok := byRepo[out[i].FullName]
+		if !ok {
+			continue
+		}
+		errs := enrichRepo(ctx, client, token, opts, &out[i], only)
+		for _, e := range errs {
+			logEnrichError(e)
+			out[i].markStatsPending(e.Endpoint, true)
+		}
+		failed = append(failed, errs...)
+		// Steps not listed weren't pending, so the flag is down to these
+		out[i].StatsCachePending = len(out[i].statsPending) > 0
+		sleepCtx(ctx, requestDelay)
+	}
+
+	if err := writeMergedIndex(out, opts); err != nil {
+		return err
+	}
+	still := statsPendingOf(out)
+	if err := writeErrorsFile(pendingPath, still); err != nil {
+		return err
+	}
+
+	logInfof("✓ Filled in %d of %d pending stats; %d still pending", len(pending)-len(still), len(pending), len(still))
+	return nil
+}
+
+// writeMergedIndex rewrites the index and summary after a partial re-run,
+// keeping the owner of the existing summary.
+func writeMergedIndex(out []outRepo, opts options) error {
+	applyContentHashes(out, opts.Hash)
+	sum := buildSummary(out, opts)
+	if data, err := os.ReadFile(summaryPath); err == nil {
+		var prev summary
+		if json.Unmarshal(data, &prev) == nil {
+			sum.Owner = prev.Owner
+		}
+	}
+
+	indexJSON, _ := json.MarshalIndent(out, "", "  ")
+	summaryJSON, _ := json.MarshalIndent(sum, "", "  ")
+	if err := os.WriteFile(indexPath, indexJSON, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(summaryPath, summaryJSON, 0644)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplayErrorsKeepsReposMissingFromIndex(t *testing.T) {
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Go":100}`)
+	}))
+	dir := t.TempDir()
+	oldIndex, oldSummary := indexPath, summaryPath
+	indexPath, summaryPath = filepath.Join(dir, indexFile), filepath.Join(dir, summaryFile)
+	t.Cleanup(func() { indexPath, summaryPath = oldIndex, oldSummary })
+
+	r := outRepo{FullName: "o/a"}
+	r.EnrichmentErrors = map[string]string{"languages": "languages error 502"}
+	index, _ := json.Marshal([]outRepo{r})
+	if err := os.WriteFile(indexPath, index, 0644); err != nil {
+		t.Fatal(err)
+	}
+	errs := []enrichError{
+		{FullName: "o/a", Endpoint: "languages", Error: "languages error 502"},
+		{FullName: "o/renamed", Endpoint: "commits", Error: "commits list error 502"},
+	}
+	errorsPath := filepath.Join(dir, errorsFile)
+	if err := writeErrorsFile(errorsPath, errs); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := parseOptions([]string{"--replay-errors", errorsPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runReplayErrors(context.Background(), http.DefaultClient, "token", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	left, err := loadErrorsFile(errorsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 1 || left[0] != errs[1] {
+		t.Errorf("errors file now %+v, want just the o/renamed entry", left)
+	}
+	out, err := loadPreviousIndex(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out[0].LanguageBreakdown["Go"] != 100 || len(out[0].EnrichmentErrors) > 0 {
+		t.Errorf("o/a after the replay: languages %v, errors %v", out[0].LanguageBreakdown, out[0].EnrichmentErrors)
+	}
+}
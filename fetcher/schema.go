@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jsonField is one key of a struct's JSON encoding.
+type jsonField struct {
+	Name string
+	Type reflect.Type
+}
+
+// jsonFields returns the JSON keys a struct type marshals to, in
+// declaration order. Embedded structs are flattened the same way
+// encoding/json does it, and fields tagged "-" are skipped.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(f.Type)...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{Name: name, Type: f.Type})
+	}
+	return fields
+}
+
+func jsonFieldNames(t reflect.Type) []string {
+	fields := jsonFields(t)
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// toJSONMap converts a value to its generic JSON form, which is how the
+// field-based features address outRepo without naming Go fields.
+func toJSONMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// runSchemaDiff compares the keys used by the records in an index file
+// against the enriched outRepo schema. It returns an error when the file
+// uses a key the enriched schema doesn't carry, since consumers reading
+// that key would break when switching to the enriched output.
+func runSchemaDiff(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var records []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	fileKeys := map[string]bool{}
+	for _, r := range records {
+		for k := range r {
+			fileKeys[k] = true
+		}
+	}
+
+	enriched := map[string]bool{}
+	for _, k := range jsonFieldNames(reflect.TypeOf(outRepo{})) {
+		enriched[k] = true
+	}
+
+	var missing, added []string
+	for k := range fileKeys {
+		if !enriched[k] {
+			missing = append(missing, k)
+		}
+	}
+	for k := range enriched {
+		if !fileKeys[k] {
+			added = append(added, k)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(added)
+
+	fmt.Printf("Schema diff: %s (%d records) vs enriched outRepo\n", path, len(records))
+	fmt.Printf("  Only in enriched (%d): %s\n", len(added), strings.Join(added, ", "))
+	fmt.Printf("  Missing from enriched (%d): %s\n", len(missing), strings.Join(missing, ", "))
+
+	if len(missing) > 0 {
+		return fmt.Errorf("enriched schema is not a superset of %s", path)
+	}
+	return nil
+}
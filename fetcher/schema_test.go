@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestOutRepoCoversGHRepo checks that every key of the GitHub repo listing
+// reaches the enriched record, under its own name or the one listed here.
+func TestOutRepoCoversGHRepo(t *testing.T) {
+	renamed := map[string][]string{
+		"size":              {"size_kb"},
+		"watchers_count":    {"watchers"},
+		"open_issues_count": {"open_issues"},
+		"owner":             {"owner_login", "owner_type"},
+	}
+
+	enriched := map[string]bool{}
+	for _, k := range jsonFieldNames(reflect.TypeOf(outRepo{})) {
+		enriched[k] = true
+	}
+	for _, k := range jsonFieldNames(reflect.TypeOf(ghRepo{})) {
+		names, ok := renamed[k]
+		if !ok {
+			names = []string{k}
+		}
+		for _, n := range names {
+			if !enriched[n] {
+				t.Errorf("ghRepo key %q: outRepo has no %q", k, n)
+			}
+		}
+	}
+}
+
+func TestSchemaDiffBasicIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repos_index.json")
+	record := `[{"name":"r","full_name":"o/r","description":"","private":false,"language":"Go",
+		"size_kb":1,"size_readable":"1 KB","stargazers_count":2,"forks_count":3,
+		"updated_at":"2024-01-01T00:00:00Z","html_url":"https://github.com/o/r",
+		"owner_login":"o","owner_type":"User"}]`
+	if err := os.WriteFile(path, []byte(record), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSchemaDiff(path); err != nil {
+		t.Error(err)
+	}
+}
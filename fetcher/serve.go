@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// repoServer holds the latest run in memory and serves it as JSON:
+//
+//   - GET /repos: the index (projected by --fields when given)
+//   - GET /repos/{owner}/{name}: one repo of the index
+//   - GET /summary: the summary
+//   - POST /refresh: start a new run in the background; the previous
+//     results are served until it finishes
+type repoServer struct {
+	opts options
+	run  func(ctx context.Context) ([]outRepo, summary, error)
+
+	mu     sync.RWMutex
+	out    []outRepo
+	sum    summary
+	byName map[string]int // lowercased full name -> index in out
+
+	refreshing sync.Mutex
+}
+
+// serveTimeout bounds each request handled by --serve.
+const serveTimeout = 30 * time.Second
+
+// runServer runs the enrichment once, then serves the results on
+// opts.Serve until ctx is done. run does one full run; --timeout bounds
+// each run rather than the server.
+func runServer(ctx context.Context, opts options, run func(ctx context.Context) ([]outRepo, summary, error)) error {
+	// Listen first, so a bad address fails before a long run
+	ln, err := net.Listen("tcp", opts.Serve)
+	if err != nil {
+		return err
+	}
+
+	s := &repoServer{opts: opts, run: run}
+	s.refreshing.Lock()
+	err = s.refresh(ctx)
+	s.refreshing.Unlock()
+	if err != nil {
+		ln.Close()
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos", s.handleRepos)
+	mux.HandleFunc("GET /repos/{owner}/{name}", s.handleRepo)
+	mux.HandleFunc("GET /summary", s.handleSummary)
+	mux.HandleFunc("POST /refresh", func(w http.ResponseWriter, r *http.Request) {
+		if !s.refreshing.TryLock() {
+			http.Error(w, "a refresh is already running", http.StatusConflict)
+			return
+		}
+		go func() {
+			defer s.refreshing.Unlock()
+			if err := s.refresh(ctx); err != nil {
+				logWarnf("⚠️  Refresh failed, still serving the previous results: %v", err)
+			}
+		}()
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "refreshing"})
+	})
+
+	srv := &http.Server{Handler: http.TimeoutHandler(mux, serveTimeout, "request timed out")}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	logInfof("🌐 Serving on http://%s (/repos, /repos/{owner}/{name}, /summary, POST /refresh)", ln.Addr())
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// refresh does a run and swaps its results in. The caller holds
+// s.refreshing, so only one runs at a time.
+func (s *repoServer) refresh(ctx context.Context) error {
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+	out, sum, err := s.run(ctx)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]int, len(out))
+	for i, r := range out {
+		byName[strings.ToLower(r.FullName)] = i
+	}
+	s.mu.Lock()
+	s.out, s.sum, s.byName = out, sum, byName
+	s.mu.Unlock()
+	logInfof("✓ Serving %d repositories", len(out))
+	return nil
+}
+
+func (s *repoServer) handleRepos(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	out := s.out
+	s.mu.RUnlock()
+
+	if s.opts.fieldList != nil {
+		projected, err := projectFields(out, s.opts.fieldList)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, projected)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *repoServer) handleRepo(w http.ResponseWriter, r *http.Request) {
+	full := strings.ToLower(r.PathValue("owner") + "/" + r.PathValue("name"))
+	s.mu.RLock()
+	i, ok := s.byName[full]
+	var repo outRepo
+	if ok {
+		repo = s.out[i]
+	}
+	s.mu.RUnlock()
+
+	if !ok {
+		http.Error(w, "no such repository in the index", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, repo)
+}
+
+func (s *repoServer) handleSummary(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	sum := s.sum
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, sum)
+}
+
+// writeJSON writes v indented, like the output files.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
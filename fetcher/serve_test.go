@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRefreshKeepsPreviousResultsOnError(t *testing.T) {
+	runErr := error(nil)
+	s := &repoServer{run: func(ctx context.Context) ([]outRepo, summary, error) {
+		if runErr != nil {
+			return nil, summary{}, runErr
+		}
+		return []outRepo{{FullName: "o/r"}}, summary{}, nil
+	}}
+	if err := s.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	runErr = context.DeadlineExceeded
+	if err := s.refresh(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("refresh error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if _, ok := s.byName["o/r"]; !ok || len(s.out) != 1 {
+		t.Errorf("serving %v after a failed refresh, want the previous o/r", s.out)
+	}
+}
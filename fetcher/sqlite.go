@@ -0,0 +1,141 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// writeSQLite writes the run into a fresh SQLite database at path:
+//
+//   - repos: one row per repo, one column per index key; lists and maps
+//     are stored as JSON text, so json_each() can reach into them
+//   - repo_topics, repo_languages, repo_contributors: the topics, language
+//     bytes and top contributors of each repo, keyed by full_name
+//   - summary: one row per top-level summary key, its value as JSON
+//
+// An existing file is replaced, and everything is inserted in a single
+// transaction.
+func writeSQLite(path string, out []outRepo, sum summary) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	fields := jsonFields(reflect.TypeOf(outRepo{}))
+	cols := make([]string, len(fields))
+	for i, f := range fields {
+		cols[i] = fmt.Sprintf("%q %s", f.Name, sqliteType(f.Type))
+	}
+	schema := []string{
+		fmt.Sprintf("CREATE TABLE repos (%s, PRIMARY KEY (full_name))", strings.Join(cols, ", ")),
+		"CREATE TABLE repo_topics (full_name TEXT NOT NULL REFERENCES repos(full_name), topic TEXT NOT NULL)",
+		"CREATE TABLE repo_languages (full_name TEXT NOT NULL REFERENCES repos(full_name), language TEXT NOT NULL, bytes INTEGER NOT NULL)",
+		"CREATE TABLE repo_contributors (full_name TEXT NOT NULL REFERENCES repos(full_name), login TEXT NOT NULL, contributions INTEGER NOT NULL)",
+		"CREATE TABLE summary (key TEXT PRIMARY KEY, value TEXT)",
+	}
+	for _, stmt := range schema {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = fmt.Sprintf("%q", f.Name)
+	}
+	insertRepo := fmt.Sprintf("INSERT INTO repos (%s) VALUES (%s)",
+		strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(fields)), ", "))
+
+	for _, r := range out {
+		m, err := toJSONMap(r)
+		if err != nil {
+			return err
+		}
+		values := make([]interface{}, len(fields))
+		for i, f := range fields {
+			if values[i], err = sqliteValue(m[f.Name]); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(insertRepo, values...); err != nil {
+			return fmt.Errorf("%s: %w", r.FullName, err)
+		}
+
+		for _, topic := range r.Topics {
+			if _, err := tx.Exec("INSERT INTO repo_topics VALUES (?, ?)", r.FullName, topic); err != nil {
+				return err
+			}
+		}
+		for lang, bytes := range r.LanguageBreakdown {
+			if _, err := tx.Exec("INSERT INTO repo_languages VALUES (?, ?, ?)", r.FullName, lang, bytes); err != nil {
+				return err
+			}
+		}
+		for _, c := range r.TopContributors {
+			if _, err := tx.Exec("INSERT INTO repo_contributors VALUES (?, ?, ?)", r.FullName, c.Login, c.Contributions); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := json.Marshal(sum)
+	if err != nil {
+		return err
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return err
+	}
+	for key, value := range top {
+		if _, err := tx.Exec("INSERT INTO summary VALUES (?, ?)", key, string(value)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// sqliteType is the column type a field of the given Go type is stored as.
+func sqliteType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "TEXT"
+	case reflect.Bool, reflect.Int, reflect.Int64:
+		return "INTEGER"
+	case reflect.Float64:
+		return "REAL"
+	}
+	return "TEXT" // JSON
+}
+
+// sqliteValue converts a generic JSON value into a column value; booleans
+// become 0/1 and composite values JSON text.
+func sqliteValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, string, float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// buildSummary aggregates the enriched repos into the account summary. The
+// owner section is account-level data and is left for the caller to fill.
+func buildSummary(out []outRepo, opts options) summary {
+	var sum summary
+	sum.GeneratedAt = formatTimestamp(time.Now())
+	sum.ToolVersion = version
+	sum.Languages = map[string]int{}
+	sum.Topics = map[string]int{}
+	sum.Licenses = map[string]int{}
+	sum.StarBuckets = map[string]int{}
+	sum.Distributions.Stars = newDistribution(opts.starBounds)
+	sum.Distributions.OpenIssues = newDistribution(opts.openIssueBounds)
+	starLabels := bucketLabels(opts.starBounds)
+	for _, l := range starLabels {
+		sum.StarBuckets[l] = 0
+	}
+
+	var newestUpdate, newestPush, oldestCreated, oldestUpdate time.Time
+	var hasUpdate, hasPush, hasCreated, hasOldUpdate bool
+	healthTotal := 0
+	closeRatioTotal := 0.0
+	var cadences []float64
+	prAuthors := map[string]bool{} // login -> internal somewhere
+	participationAll, participationOwner := 0, 0
+	concentrationTotal, concentrationRepos := 0.0, 0
+
+	sum.ByOwner = map[string]ownerStats{}
+	for _, r := range out {
+		sum.RepoCounts.Total++
+
+		o := sum.ByOwner[r.OwnerLogin]
+		o.Repos++
+		o.Stars += r.Stars
+		o.Forks += r.Forks
+		o.SizeKB += r.SizeKB
+		o.Commits += r.TotalCommits
+		sum.ByOwner[r.OwnerLogin] = o
+
+		if r.Private {
+			sum.RepoCounts.Private++
+		} else {
+			sum.RepoCounts.Public++
+		}
+
+		if r.Archived {
+			sum.RepoCounts.Archived++
+		}
+
+		if r.Fork {
+			sum.RepoCounts.Forks++
+		}
+
+		if r.OwnerType == "Organization" {
+			sum.RepoCounts.Org++
+		} else {
+			sum.RepoCounts.User++
+		}
+
+		if r.LatestReleaseTag != "" {
+			sum.RepoCounts.WithReleases++
+		}
+
+		switch r.MyRole {
+		case "owner":
+			sum.RepoCounts.OwnedByMe++
+		case "organization_member":
+			sum.RepoCounts.OrgRepos++
+		case "collaborator":
+			sum.RepoCounts.CollaboratorRepos++
+		}
+
+		sum.Size.TotalKB += r.SizeKB
+		sum.Engagement.TotalStars += r.Stars
+		sum.Engagement.TotalForks += r.Forks
+		sum.Engagement.TotalWatchers += r.Watchers
+		sum.Engagement.TotalCommits += r.TotalCommits
+		sum.Engagement.TotalLifetimeCommits += r.LifetimeCommits
+		sum.Engagement.TotalOpenPRs += r.OpenPRs
+		sum.Engagement.TotalOpenIssuesOnly += r.OpenIssuesOnly
+		if r.ContributionConcentration > 0 {
+			concentrationTotal += r.ContributionConcentration
+			concentrationRepos++
+		}
+		for i, n := range r.ParticipationAll {
+			participationAll += n
+			if i < len(r.ParticipationOwner) {
+				participationOwner += r.ParticipationOwner[i]
+			}
+		}
+		for _, w := range r.WeeklyCodeFrequency {
+			sum.Engagement.TotalChurn52W += w.Additions + w.Deletions
+		}
+		sum.StarBuckets[starLabels[bucketIndex(r.Stars, opts.starBounds)]]++
+		sum.Distributions.Stars.Counts[bucketIndex(r.Stars, opts.starBounds)]++
+		sum.Distributions.OpenIssues.Counts[bucketIndex(r.OpenIssues, opts.openIssueBounds)]++
+
+		if r.Language != "" {
+			sum.Languages[r.Language]++
+		}
+
+		for _, topic := range r.Topics {
+			// An index from an older run may still hold blank topics
+			if strings.TrimSpace(topic) == "" {
+				continue
+			}
+			sum.Topics[topic]++
+		}
+
+		if r.License != "" {
+			sum.Licenses[r.License]++
+		}
+
+		// Timestamps
+		if t, err := time.Parse(time.RFC3339, r.UpdatedAt); err == nil {
+			if !hasUpdate || t.After(newestUpdate) {
+				newestUpdate = t
+				hasUpdate = true
+			}
+			if !hasOldUpdate || t.Before(oldestUpdate) {
+				oldestUpdate = t
+				hasOldUpdate = true
+			}
+		}
+
+		if t, err := time.Parse(time.RFC3339, r.PushedAt); err == nil {
+			if !hasPush || t.After(newestPush) {
+				newestPush = t
+				hasPush = true
+			}
+		}
+
+		if t, err := time.Parse(time.RFC3339, r.CreatedAt); err == nil {
+			if !hasCreated || t.Before(oldestCreated) {
+				oldestCreated = t
+				hasCreated = true
+			}
+		}
+
+		// Enrichment counters, for the enrichments this run did; data
+		// carried over from a previous run doesn't count otherwise
+		if opts.EnrichCommits && r.LastCommitAt != "" {
+			sum.Enrichment.ReposWithLastCommit++
+		}
+		if opts.EnrichStats && len(r.WeeklyCommits52W) > 0 {
+			sum.Enrichment.ReposWithStats52W++
+		}
+		if opts.EnrichLanguages && len(r.LanguageBreakdown) > 0 {
+			sum.Enrichment.ReposWithLanguages++
+		}
+		if opts.EnrichContributors && len(r.TopContributors) > 0 {
+			sum.Enrichment.ReposWithContributors++
+		}
+		if r.StatsCachePending {
+			sum.Enrichment.ReposStatsPending++
+		}
+		if r.RepoDeleted {
+			sum.Enrichment.ReposDeleted++
+		}
+		if r.ImportPending {
+			sum.Enrichment.ReposImportPending++
+		}
+		if r.IsEmpty {
+			sum.Enrichment.ReposEmpty++
+		}
+		if len(r.EnrichmentErrors) > 0 {
+			sum.Enrichment.ReposWithErrors++
+		}
+		if r.AvgDaysBetweenCommits > 0 {
+			cadences = append(cadences, r.AvgDaysBetweenCommits)
+		}
+
+		if r.HasCommunityProfile {
+			sum.Community.ReposWithProfile++
+			healthTotal += r.CommunityHealthPercentage
+			if r.HasCodeOfConduct {
+				sum.Community.ReposWithCodeOfConduct++
+			}
+			if r.HasContributing {
+				sum.Community.ReposWithContributing++
+			}
+		}
+
+		if r.MergeSettingsKnown && opts.mergePolicy != nil {
+			sum.MergePolicy.ReposChecked++
+			if d := opts.mergePolicy.deviations(r); len(d) > 0 {
+				if sum.MergePolicy.Deviations == nil {
+					sum.MergePolicy.Deviations = map[string][]string{}
+				}
+				sum.MergePolicy.ReposDeviating++
+				sum.MergePolicy.Deviations[r.FullName] = d
+			}
+		}
+
+		if r.IssuesTotal > 0 {
+			sum.Issues.ReposWithIssueData++
+			closeRatioTotal += r.IssueCloseRatio
+			if r.HighOpenIssueRatio {
+				sum.Issues.ReposHighOpenRatio++
+			}
+		}
+
+		if r.DefaultWorkflowPermissions != "" && r.DefaultWorkflowPermissions != workflowPermissionsDisabled {
+			sum.WorkflowPermissions.ReposChecked++
+			if r.DefaultWorkflowPermissions == "write" {
+				sum.WorkflowPermissions.ReposDefaultWrite++
+				sum.WorkflowPermissions.DefaultWrite = append(sum.WorkflowPermissions.DefaultWrite, r.FullName)
+			}
+		}
+
+		for _, p := range r.CIProviders {
+			if sum.CIProviders == nil {
+				sum.CIProviders = map[string]int{}
+			}
+			sum.CIProviders[p]++
+		}
+
+		if len(r.OpenPRAuthors) > 0 {
+			sum.PullRequests.ReposWithOpenPRs++
+			external := map[string]bool{}
+			for _, login := range r.ExternalPRAuthors {
+				external[login] = true
+			}
+			for _, login := range r.OpenPRAuthors {
+				prAuthors[login] = prAuthors[login] || !external[login]
+			}
+		}
+	}
+	for _, internal := range prAuthors {
+		if internal {
+			sum.PullRequests.InternalAuthors++
+		} else {
+			sum.PullRequests.ExternalAuthors++
+		}
+	}
+
+	sum.Size.Human = humanSizeFromKB(sum.Size.TotalKB)
+	if sum.Community.ReposWithProfile > 0 {
+		avg := float64(healthTotal) / float64(sum.Community.ReposWithProfile)
+		sum.Community.AvgHealthPercentage = math.Round(avg*10) / 10
+	}
+	if len(cadences) > 0 {
+		sort.Float64s(cadences)
+		sum.Cadence.ReposWithCommits = len(cadences)
+		sum.Cadence.MinAvgDaysBetween = cadences[0]
+		sum.Cadence.MaxAvgDaysBetween = cadences[len(cadences)-1]
+		sum.Cadence.MedianAvgDaysBetween = median(cadences)
+	}
+	if sum.Issues.ReposWithIssueData > 0 {
+		avg := closeRatioTotal / float64(sum.Issues.ReposWithIssueData)
+		sum.Issues.AvgIssueCloseRatio = math.Round(avg*1000) / 1000
+	}
+	if concentrationRepos > 0 {
+		avg := concentrationTotal / float64(concentrationRepos)
+		sum.Engagement.AvgContributionConcentration = math.Round(avg*1000) / 1000
+	}
+	if participationAll > 0 {
+		ratio := float64(participationAll-participationOwner) / float64(participationAll)
+		sum.Engagement.CommunityCommitRatio = math.Round(ratio*1000) / 1000
+	}
+	if hasUpdate {
+		sum.Activity.MostRecentUpdate = formatTimestamp(newestUpdate)
+	}
+	if hasPush {
+		sum.Activity.MostRecentPush = formatTimestamp(newestPush)
+	}
+	if hasCreated {
+		sum.Activity.OldestCreated = formatTimestamp(oldestCreated)
+	}
+	if hasOldUpdate {
+		sum.Activity.OldestUpdate = formatTimestamp(oldestUpdate)
+	}
+
+	sum.LanguageSummary = languageSummary(out)
+	sum.LanguageBytes = map[string]int64{}
+	sum.LanguagePercent = map[string]float64{}
+	for _, st := range sum.LanguageSummary {
+		if st.Bytes > 0 {
+			sum.LanguageBytes[st.Language] = st.Bytes
+			sum.LanguagePercent[st.Language] = st.Percent
+		}
+	}
+	sum.ContributorLeaderboard = contributorLeaderboard(out, leaderboardSize)
+	sum.SlowestRepos = slowestRepos(out, slowestReposSize)
+	sum.BinaryHeavyRepos = binaryHeavyRepos(out, binaryHeavySize)
+	sum.DuplicateDescriptions = duplicateDescriptions(out)
+
+	return sum
+}
+
+// leaderboardSize caps the summary's contributor leaderboard.
+const leaderboardSize = 50
+
+// contributorLeaderboard sums each login's contributions over every repo's
+// top contributors, highest first, ties broken by login.
+func contributorLeaderboard(out []outRepo, limit int) []contributor {
+	totals := map[string]int{}
+	for _, r := range out {
+		for _, c := range r.TopContributors {
+			totals[c.Login] += c.Contributions
+		}
+	}
+
+	board := make([]contributor, 0, len(totals))
+	for login, n := range totals {
+		board = append(board, contributor{Login: login, Contributions: n})
+	}
+	sort.Slice(board, func(i, j int) bool {
+		if board[i].Contributions != board[j].Contributions {
+			return board[i].Contributions > board[j].Contributions
+		}
+		return board[i].Login < board[j].Login
+	})
+	if len(board) > limit {
+		board = board[:limit]
+	}
+	return board
+}
+
+// slowestReposSize caps the summary's list of slowest-enriching repos.
+const slowestReposSize = 10
+
+// slowestRepos lists the repos that took longest to enrich, e.g. because
+// their stats endpoint kept answering 202. Only repos timed with --timings
+// are considered.
+func slowestRepos(out []outRepo, limit int) []repoTiming {
+	var timings []repoTiming
+	for _, r := range out {
+		if r.EnrichDurationMs > 0 {
+			timings = append(timings, repoTiming{FullName: r.FullName, DurationMs: r.EnrichDurationMs})
+		}
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		if timings[i].DurationMs != timings[j].DurationMs {
+			return timings[i].DurationMs > timings[j].DurationMs
+		}
+		return timings[i].FullName < timings[j].FullName
+	})
+	if len(timings) > limit {
+		timings = timings[:limit]
+	}
+	return timings
+}
+
+// languageSummary combines repo counts and byte totals per language, sorted
+// by bytes. A repo counts toward every language in its breakdown, or toward
+// its primary language when it has no breakdown.
+func languageSummary(out []outRepo) []langStat {
+	byLang := map[string]*langStat{}
+	stat := func(lang string) *langStat {
+		if byLang[lang] == nil {
+			byLang[lang] = &langStat{Language: lang}
+		}
+		return byLang[lang]
+	}
+
+	var totalBytes int64
+	for _, r := range out {
+		if len(r.LanguageBreakdown) == 0 {
+			if r.Language != "" {
+				stat(r.Language).Repos++
+			}
+			continue
+		}
+		for lang, n := range r.LanguageBreakdown {
+			st := stat(lang)
+			st.Repos++
+			st.Bytes += int64(n)
+			totalBytes += int64(n)
+		}
+	}
+
+	stats := make([]langStat, 0, len(byLang))
+	for _, st := range byLang {
+		if totalBytes > 0 {
+			st.Percent = math.Round(float64(st.Bytes)/float64(totalBytes)*10000) / 100
+		}
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Bytes != stats[j].Bytes {
+			return stats[i].Bytes > stats[j].Bytes
+		}
+		if stats[i].Repos != stats[j].Repos {
+			return stats[i].Repos > stats[j].Repos
+		}
+		return stats[i].Language < stats[j].Language
+	})
+	return stats
+}
+
+const (
+	// binaryHeavySize caps the summary's list of binary-heavy repos.
+	binaryHeavySize = 10
+	// binaryHeavyMinKB keeps small repos, where a README alone skews the
+	// ratio, out of the binary-heavy list.
+	binaryHeavyMinKB = 1024
+	// binaryHeavyRatio is the non-code share from which a repo counts as
+	// mostly binaries.
+	binaryHeavyRatio = 0.9
+)
+
+// binaryHeavyRepos lists the repos whose size is mostly not code, highest
+// ratio first.
+func binaryHeavyRepos(out []outRepo, limit int) []nonCodeRepo {
+	heavy := []nonCodeRepo{}
+	for _, r := range out {
+		if r.SizeKB >= binaryHeavyMinKB && r.NonCodeRatio >= binaryHeavyRatio {
+			heavy = append(heavy, nonCodeRepo{FullName: r.FullName, SizeKB: r.SizeKB, NonCodeRatio: r.NonCodeRatio})
+		}
+	}
+	sort.Slice(heavy, func(i, j int) bool {
+		if heavy[i].NonCodeRatio != heavy[j].NonCodeRatio {
+			return heavy[i].NonCodeRatio > heavy[j].NonCodeRatio
+		}
+		return heavy[i].SizeKB > heavy[j].SizeKB
+	})
+	if len(heavy) > limit {
+		heavy = heavy[:limit]
+	}
+	return heavy
+}
+
+// duplicateDescriptions groups repos by their trimmed, non-empty
+// description and keeps the groups with more than one repo.
+func duplicateDescriptions(out []outRepo) map[string][]string {
+	byDesc := map[string][]string{}
+	for _, r := range out {
+		if d := strings.TrimSpace(r.Description); d != "" {
+			byDesc[d] = append(byDesc[d], r.FullName)
+		}
+	}
+	dups := map[string][]string{}
+	for d, names := range byDesc {
+		if len(names) > 1 {
+			sort.Strings(names)
+			dups[d] = names
+		}
+	}
+	return dups
+}
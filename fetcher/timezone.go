@@ -0,0 +1,22 @@
+package main
+
+import "time"
+
+// outputLocation is the zone output timestamps are written in (--timezone).
+var outputLocation = time.UTC
+
+// formatTimestamp writes t as RFC 3339 in the output zone.
+func formatTimestamp(t time.Time) string {
+	return t.In(outputLocation).Format(time.RFC3339)
+}
+
+// localizeTimestamp rewrites an API timestamp into the output zone. It's
+// the same instant either way, so anything parsing the output as RFC 3339
+// is unaffected. Values that don't parse are kept as they are.
+func localizeTimestamp(s string) string {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return s
+	}
+	return formatTimestamp(t)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// errNoToken is returned when no way of finding a token worked.
+var errNoToken = errors.New("no GitHub token found. Put GITHUB_TOKEN=ghp_... (no quotes) in .env or export it, " +
+	"point --token-file or GITHUB_TOKEN_FILE at a file holding it, or log in with `gh auth login`")
+
+// loadToken finds the API token and says where it came from, trying in
+// order: --token-file, GITHUB_TOKEN (from the environment or the env file,
+// as envSource describes), the file named by GITHUB_TOKEN_FILE, and
+// finally the GitHub CLI's `gh auth token`.
+func loadToken(tokenFile, envSource string) (token, source string, err error) {
+	if tokenFile != "" {
+		return readTokenFile(tokenFile, "--token-file")
+	}
+	if token := strings.TrimSpace(os.Getenv("GITHUB_TOKEN")); token != "" {
+		return token, "GITHUB_TOKEN in " + envSource, nil
+	}
+	if path := os.Getenv("GITHUB_TOKEN_FILE"); path != "" {
+		return readTokenFile(path, "GITHUB_TOKEN_FILE")
+	}
+	if token := ghAuthToken(); token != "" {
+		return token, "gh auth token", nil
+	}
+	return "", "", errNoToken
+}
+
+func readTokenFile(path, flag string) (string, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", flag, err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", "", fmt.Errorf("%s: %s is empty", flag, path)
+	}
+	return token, path, nil
+}
+
+// ghAuthToken asks the GitHub CLI for its token, for the Enterprise host
+// when --base-url points at one. It is empty when gh isn't installed or
+// isn't logged in.
+func ghAuthToken() string {
+	args := []string{"auth", "token"}
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" && baseURL != defaultBaseURL {
+		args = append(args, "--hostname", u.Hostname())
+	}
+	out, err := exec.Command("gh", args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,28 @@
+package main
+
+import "strings"
+
+// maxTopicsPerRepo is GitHub's own limit on topics per repo; anything past
+// it is kept out of the index and the histogram.
+const maxTopicsPerRepo = 20
+
+// cleanTopics trims the listing's topics and drops empty strings (which
+// GitHub occasionally returns) and duplicates, keeping at most
+// maxTopicsPerRepo.
+func cleanTopics(fullName string, topics []string) []string {
+	cleaned := make([]string, 0, len(topics))
+	seen := map[string]bool{}
+	for _, t := range topics {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		cleaned = append(cleaned, t)
+	}
+	if len(cleaned) > maxTopicsPerRepo {
+		logWarnf("⚠️  %s has %d topics, keeping the first %d", fullName, len(cleaned), maxTopicsPerRepo)
+		cleaned = cleaned[:maxTopicsPerRepo]
+	}
+	return cleaned
+}
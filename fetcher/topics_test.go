@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestCleanTopics(t *testing.T) {
+	discardLogs(t)
+
+	many := make([]string, maxTopicsPerRepo+5)
+	for i := range many {
+		many[i] = fmt.Sprintf("t%d", i)
+	}
+
+	tests := []struct {
+		name   string
+		topics []string
+		want   []string
+	}{
+		{"nil", nil, []string{}},
+		{"empty string", []string{""}, []string{}},
+		{"empty among others", []string{"go", "", "cli"}, []string{"go", "cli"}},
+		{"whitespace", []string{" go ", "  "}, []string{"go"}},
+		{"duplicates", []string{"go", "cli", "go", " cli"}, []string{"go", "cli"}},
+		{"over the limit", many, many[:maxTopicsPerRepo]},
+	}
+	for _, tt := range tests {
+		got := cleanTopics("o/r", tt.topics)
+		if !slices.Equal(got, tt.want) || got == nil {
+			t.Errorf("%s: cleanTopics(%q) = %q, want %q", tt.name, tt.topics, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type ghUser struct {
+	Login       string `json:"login"`
+	Name        string `json:"name"`
+	Followers   int    `json:"followers"`
+	Following   int    `json:"following"`
+	PublicRepos int    `json:"public_repos"`
+}
+
+// fetchAuthenticatedUser returns the account the token belongs to.
+func fetchAuthenticatedUser(ctx context.Context, client *http.Client, token string) (ghUser, error) {
+	status, body, err := doGET(ctx, client, apiURL("/user"), token)
+	if err != nil {
+		return ghUser{}, err
+	}
+	if status < 200 || status >= 300 {
+		return ghUser{}, fmt.Errorf("user error %d", status)
+	}
+
+	var u ghUser
+	if err := json.Unmarshal(body, &u); err != nil {
+		return ghUser{}, err
+	}
+	return u, nil
+}
+
+// fetchUser returns the public profile of a user or organization login.
+func fetchUser(ctx context.Context, client *http.Client, token, login string) (ghUser, error) {
+	status, body, err := doGET(ctx, client, apiURL("/users/"+url.PathEscape(login)), token)
+	if err != nil {
+		return ghUser{}, err
+	}
+	if status == 404 {
+		return ghUser{}, fmt.Errorf("no user or organization named %q", login)
+	}
+	if status < 200 || status >= 300 {
+		return ghUser{}, fmt.Errorf("user error %d", status)
+	}
+
+	var u ghUser
+	if err := json.Unmarshal(body, &u); err != nil {
+		return ghUser{}, err
+	}
+	return u, nil
+}
+
+// listingPath is the endpoint the repos are listed from: the repos the
+// token can access, or with --user/--org the repos of that account (only
+// the public ones, unless the token can see more).
+func listingPath(user, org string) string {
+	switch {
+	case user != "":
+		return fmt.Sprintf("/users/%s/repos?type=owner", url.PathEscape(user))
+	case org != "":
+		return fmt.Sprintf("/orgs/%s/repos?type=all", url.PathEscape(org))
+	}
+	return "/user/repos?affiliation=owner,collaborator,organization_member"
+}
+
+// repoRole describes how the authenticated user relates to a repo, using
+// the same vocabulary as the /user/repos affiliation filter. Only that
+// listing (affiliated) says the user is a collaborator or member on the
+// repos they don't own; a --user or --org listing has everyone's repos, so
+// those get no role there. It is empty when the login isn't known.
+func repoRole(ownerLogin, ownerType, me string, affiliated bool) string {
+	switch {
+	case me == "":
+		return ""
+	case strings.EqualFold(ownerLogin, me):
+		return "owner"
+	case !affiliated:
+		return ""
+	case ownerType == "Organization":
+		return "organization_member"
+	default:
+		return "collaborator"
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestRepoRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		owner      string
+		ownerType  string
+		affiliated bool
+		want       string
+	}{
+		{"own repo", "Me", "User", true, "owner"},
+		{"collaborator", "other", "User", true, "collaborator"},
+		{"org member", "acme", "Organization", true, "organization_member"},
+		{"own repo in a --user listing", "me", "User", false, "owner"},
+		{"--user listing of someone else", "other", "User", false, ""},
+		{"--org listing", "acme", "Organization", false, ""},
+	}
+	for _, tt := range tests {
+		if got := repoRole(tt.owner, tt.ownerType, "me", tt.affiliated); got != tt.want {
+			t.Errorf("%s: repoRole = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := repoRole("other", "User", "", true); got != "" {
+		t.Errorf("unknown login: repoRole = %q, want empty", got)
+	}
+}
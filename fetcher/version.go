@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Build info, set at build time with e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildCommit is the injected commit, or the VCS revision Go stamped into
+// the binary when it was built from a checkout without -ldflags.
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && len(s.Value) >= 7 {
+				return s.Value[:7]
+			}
+		}
+	}
+	return "unknown"
+}
+
+func versionString() string {
+	date := buildDate
+	if date == "" {
+		date = "unknown"
+	}
+	return fmt.Sprintf("gitlore-enricher %s (commit %s, built %s)", version, buildCommit(), date)
+}
+
+// userAgent identifies the tool and its version to the API.
+func userAgent() string {
+	return "gitlore-enricher/" + version
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// whereHelp documents the --where mini-language.
+const whereHelp = `filter the output to repos matching an expression, e.g. 'stars>10 && language==Go'.
+Fields are outRepo JSON keys (stars, language, total_commits, contributor_count, ...).
+Operators: == != > >= < <= on numbers, strings and booleans; == and != on a list
+field (e.g. topics==cli) test membership. Combine with &&, || and !, group with ( ).
+A bare boolean field (e.g. 'fork') is true when the field is true. Quote strings
+containing spaces or operators: description=="my tool"`
+
+// whereExpr is a parsed --where expression evaluated against the JSON form
+// of an outRepo.
+type whereExpr interface {
+	eval(rec map[string]interface{}) bool
+}
+
+type whereAnd struct{ l, r whereExpr }
+type whereOr struct{ l, r whereExpr }
+type whereNot struct{ e whereExpr }
+
+type whereCmp struct {
+	field string
+	op    string
+	kind  reflect.Kind
+	str   string
+	num   float64
+	b     bool
+}
+
+func (e whereAnd) eval(rec map[string]interface{}) bool { return e.l.eval(rec) && e.r.eval(rec) }
+func (e whereOr) eval(rec map[string]interface{}) bool  { return e.l.eval(rec) || e.r.eval(rec) }
+func (e whereNot) eval(rec map[string]interface{}) bool { return !e.e.eval(rec) }
+
+func (e whereCmp) eval(rec map[string]interface{}) bool {
+	v := rec[e.field]
+	// A list left out (omitempty) or null is empty, not the number 0
+	if e.kind == reflect.Slice {
+		list, _ := v.([]interface{})
+		found := false
+		for _, item := range list {
+			if s, ok := item.(string); ok && s == e.str {
+				found = true
+				break
+			}
+		}
+		return found == (e.op == "==")
+	}
+
+	switch e.kind {
+	case reflect.Bool:
+		b, _ := v.(bool)
+		return compareOrdered(boolRank(b), boolRank(e.b), e.op)
+	case reflect.String:
+		s, _ := v.(string)
+		return compareOrdered(s, e.str, e.op)
+	default:
+		n, _ := v.(float64)
+		return compareOrdered(n, e.num, e.op)
+	}
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func compareOrdered[T int | float64 | string](a, b T, op string) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+// parseWhere parses a --where expression, checking field names and literal
+// types against the outRepo schema up front so typos fail before a run.
+func parseWhere(src string) (whereExpr, error) {
+	toks, err := tokenizeWhere(src)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := map[string]reflect.Kind{}
+	for _, f := range jsonFields(reflect.TypeOf(outRepo{})) {
+		kinds[f.Name] = f.Type.Kind()
+	}
+
+	p := &whereParser{toks: toks, kinds: kinds}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("where: unexpected %q", p.toks[p.pos].text)
+	}
+	return e, nil
+}
+
+type whereToken struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeWhere(src string) ([]whereToken, error) {
+	var toks []whereToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("where: unterminated string at offset %d", i)
+			}
+			toks = append(toks, whereToken{text: src[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		case strings.HasPrefix(src[i:], "&&"), strings.HasPrefix(src[i:], "||"),
+			strings.HasPrefix(src[i:], "=="), strings.HasPrefix(src[i:], "!="),
+			strings.HasPrefix(src[i:], ">="), strings.HasPrefix(src[i:], "<="):
+			toks = append(toks, whereToken{text: src[i : i+2]})
+			i += 2
+		case strings.IndexByte("()!<>", c) >= 0:
+			toks = append(toks, whereToken{text: src[i : i+1]})
+			i++
+		default:
+			// Words may be non-ASCII (language == Français), so walk runes
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if !isWhereWordChar(r) {
+					break
+				}
+				i += size
+			}
+			if i == start {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, fmt.Errorf("where: unexpected %q at offset %d", r, i)
+			}
+			toks = append(toks, whereToken{text: src[start:i]})
+		}
+	}
+	return toks, nil
+}
+
+func isWhereWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-+#/:", r)
+}
+
+type whereParser struct {
+	toks  []whereToken
+	pos   int
+	kinds map[string]reflect.Kind
+}
+
+func (p *whereParser) peek() string {
+	if p.pos < len(p.toks) && !p.toks[p.pos].quoted {
+		return p.toks[p.pos].text
+	}
+	return ""
+}
+
+func (p *whereParser) parseOr() (whereExpr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = whereOr{l, r}
+	}
+	return l, nil
+}
+
+func (p *whereParser) parseAnd() (whereExpr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = whereAnd{l, r}
+	}
+	return l, nil
+}
+
+func (p *whereParser) parseUnary() (whereExpr, error) {
+	switch p.peek() {
+	case "!":
+		p.pos++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return whereNot{e}, nil
+	case "(":
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("where: missing )")
+		}
+		p.pos++
+		return e, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *whereParser) parseComparison() (whereExpr, error) {
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("where: expression ends early")
+	}
+	field := strings.TrimPrefix(p.toks[p.pos].text, ".")
+	kind, ok := p.kinds[field]
+	if !ok {
+		return nil, fmt.Errorf("where: unknown field %q", field)
+	}
+	p.pos++
+
+	op := p.peek()
+	switch op {
+	case "==", "!=", ">", ">=", "<", "<=":
+		p.pos++
+	default:
+		if kind != reflect.Bool {
+			return nil, fmt.Errorf("where: %s needs a comparison", field)
+		}
+		return whereCmp{field: field, op: "==", kind: kind, b: true}, nil
+	}
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("where: %s %s needs a value", field, op)
+	}
+	lit := p.toks[p.pos].text
+	p.pos++
+
+	cmp := whereCmp{field: field, op: op, kind: kind, str: lit}
+	switch kind {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(lit)
+		if err != nil {
+			return nil, fmt.Errorf("where: %s is a boolean, got %q", field, lit)
+		}
+		cmp.b = b
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		n, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			return nil, fmt.Errorf("where: %s is a number, got %q", field, lit)
+		}
+		cmp.num = n
+	case reflect.Slice:
+		if op != "==" && op != "!=" {
+			return nil, fmt.Errorf("where: %s is a list; only == and != are supported", field)
+		}
+	case reflect.String:
+	default:
+		return nil, fmt.Errorf("where: %s can't be compared", field)
+	}
+	return cmp, nil
+}
+
+// filterWhere keeps the repos matching expr.
+func filterWhere(repos []outRepo, expr whereExpr) ([]outRepo, error) {
+	kept := repos[:0]
+	for _, r := range repos {
+		rec, err := toJSONMap(r)
+		if err != nil {
+			return nil, err
+		}
+		if expr.eval(rec) {
+			kept = append(kept, r)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWhereListFields(t *testing.T) {
+	repos := func() []outRepo {
+		travis, cli, bare := outRepo{FullName: "o/travis"}, outRepo{FullName: "o/cli"}, outRepo{FullName: "o/bare"}
+		travis.CIProviders = []string{"travis"}
+		cli.Topics = []string{"cli"}
+		return []outRepo{travis, cli, bare}
+	}
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"ci_providers==travis", []string{"o/travis"}},
+		{"ci_providers!=travis", []string{"o/cli", "o/bare"}},
+		{"topics==cli", []string{"o/cli"}},
+		{"topics!=cli", []string{"o/travis", "o/bare"}},
+	}
+	for _, tt := range tests {
+		expr, err := parseWhere(tt.expr)
+		if err != nil {
+			t.Fatalf("parseWhere(%q): %v", tt.expr, err)
+		}
+		kept, err := filterWhere(repos(), expr)
+		if err != nil {
+			t.Fatalf("filterWhere(%q): %v", tt.expr, err)
+		}
+		var got []string
+		for _, r := range kept {
+			got = append(got, r.FullName)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s kept %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestWhereNonASCII(t *testing.T) {
+	repos := []outRepo{
+		{FullName: "o/cafe", Description: "café", Language: "Français"},
+		{FullName: "o/plain", Description: "cafe", Language: "Go"},
+	}
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"description == café", []string{"o/cafe"}},
+		{"description==café&&language==Français", []string{"o/cafe"}},
+		{"language != Français", []string{"o/plain"}},
+		{"description == 'café'", []string{"o/cafe"}},
+	}
+	for _, tt := range tests {
+		expr, err := parseWhere(tt.expr)
+		if err != nil {
+			t.Fatalf("parseWhere(%q): %v", tt.expr, err)
+		}
+		kept, err := filterWhere(slices.Clone(repos), expr)
+		if err != nil {
+			t.Fatalf("filterWhere(%q): %v", tt.expr, err)
+		}
+		var got []string
+		for _, r := range kept {
+			got = append(got, r.FullName)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s kept %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	// A symbol is still no word character, and is reported whole
+	if _, err := parseWhere("language == €"); err == nil || !strings.Contains(err.Error(), "'€'") {
+		t.Errorf("parseWhere with a stray symbol: %v, want it reported as '€'", err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// workflowPermissionsDisabled is recorded instead of read/write when
+// Actions is turned off for the repo.
+const workflowPermissionsDisabled = "actions_disabled"
+
+// fetchWorkflowPermissions returns the default GITHUB_TOKEN permissions of
+// a repo's workflows, "read" or "write". known is false on a 403: the
+// setting needs admin access.
+func fetchWorkflowPermissions(ctx context.Context, client *http.Client, token, fullName string) (perm string, known bool, err error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/actions/permissions/workflow", fullName))
+	status, body, err := doGET(ctx, client, url, token)
+	if err != nil {
+		return "", false, err
+	}
+	switch {
+	case status == 403:
+		return "", false, nil
+	case status == 404 || status == 409:
+		return workflowPermissionsDisabled, true, nil
+	case status < 200 || status >= 300:
+		return "", false, fmt.Errorf("workflow permissions error %d", status)
+	}
+
+	var res struct {
+		DefaultWorkflowPermissions string `json:"default_workflow_permissions"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", false, err
+	}
+	return res.DefaultWorkflowPermissions, true, nil
+}
+
+// workflowRunsNoActions is recorded as the latest conclusion when Actions
+// is disabled for the repo, or not available to the token.
+const workflowRunsNoActions = "no_actions"
+
+// fetchWorkflowRuns returns the number of Actions workflow runs of a repo
+// and the outcome of the most recent one: its conclusion (success,
+// failure, cancelled, ...) once finished, else its status (queued,
+// in_progress). The outcome is empty when there are no runs.
+func fetchWorkflowRuns(ctx context.Context, client *http.Client, token, fullName string) (count int, latest string, err error) {
+	url := apiURL(fmt.Sprintf("/repos/%s/actions/runs?per_page=1", fullName))
+	status, body, err := doGET(ctx, client, url, token)
+	if err != nil {
+		return 0, "", err
+	}
+	switch {
+	case status == 403 || status == 404:
+		return 0, workflowRunsNoActions, nil
+	case status < 200 || status >= 300:
+		return 0, "", fmt.Errorf("workflow runs error %d", status)
+	}
+
+	var res struct {
+		TotalCount   int `json:"total_count"`
+		WorkflowRuns []struct {
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+		} `json:"workflow_runs"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return 0, "", err
+	}
+	if len(res.WorkflowRuns) > 0 {
+		run := res.WorkflowRuns[0]
+		latest = cmp.Or(run.Conclusion, run.Status)
+	}
+	return res.TotalCount, latest, nil
+}